@@ -0,0 +1,85 @@
+package dutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CityscapesClasses are names of the 19 Cityscapes classes used for training
+// and evaluation. Class index is the position in the list.
+var CityscapesClasses = []string{
+	"road", "sidewalk", "building", "wall", "fence",
+	"pole", "traffic light", "traffic sign", "vegetation", "terrain",
+	"sky", "person", "rider", "car", "truck",
+	"bus", "train", "motorcycle", "bicycle",
+}
+
+// CityscapesTrainIDs maps Cityscapes label IDs (as in *_gtFine_labelIds.png)
+// to train IDs (indices of CityscapesClasses). Labels not used for
+// evaluation are mapped to 255.
+// Ref. https://github.com/mcordts/cityscapesScripts/blob/master/cityscapesscripts/helpers/labels.py
+var CityscapesTrainIDs = map[int64]int64{
+	-1: 255, 0: 255, 1: 255, 2: 255, 3: 255, 4: 255, 5: 255, 6: 255,
+	7: 0, 8: 1, 9: 255, 10: 255, 11: 2, 12: 3, 13: 4,
+	14: 255, 15: 255, 16: 255, 17: 5, 18: 255, 19: 6, 20: 7,
+	21: 8, 22: 9, 23: 10, 24: 11, 25: 12, 26: 13, 27: 14, 28: 15,
+	29: 255, 30: 255, 31: 16, 32: 17, 33: 18,
+}
+
+const (
+	cityscapesImageSuffix = "_leftImg8bit.png"
+	cityscapesMaskSuffix  = "_gtFine_labelIds.png"
+)
+
+// NewCityscapesDataset creates a dataset from Cityscapes directory layout:
+//
+//	root/leftImg8bit/<split>/<city>/<city>_<seq>_<frame>_leftImg8bit.png
+//	root/gtFine/<split>/<city>/<city>_<seq>_<frame>_gtFine_labelIds.png
+//
+// split is "train", "val" or "test". Sample names are "<city>_<seq>_<frame>".
+// Label IDs are mapped to train IDs with CityscapesTrainIDs unless another
+// mapping is given with WithLabelMap, e.g. WithLabelMap(nil) keeps label IDs.
+func NewCityscapesDataset(root, split string, options ...FolderOption) (*SegmentationFolderDataset, error) {
+	imageRoot := filepath.Join(root, "leftImg8bit", split)
+	cities, err := ioutil.ReadDir(imageRoot)
+	if err != nil {
+		err = fmt.Errorf("Reading Cityscapes split %q failed: %v\n", split, err)
+		return nil, err
+	}
+
+	var (
+		names      []string
+		imageFiles []string
+		maskFiles  []string
+	)
+	for _, city := range cities {
+		if !city.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(imageRoot, city.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), cityscapesImageSuffix) {
+				continue
+			}
+			name := strings.TrimSuffix(f.Name(), cityscapesImageSuffix)
+			names = append(names, name)
+			imageFiles = append(imageFiles, filepath.Join(imageRoot, city.Name(), f.Name()))
+			maskFiles = append(maskFiles, filepath.Join(root, "gtFine", split, city.Name(), name+cityscapesMaskSuffix))
+		}
+	}
+	for i, file := range maskFiles {
+		if _, err := os.Stat(file); err != nil {
+			err = fmt.Errorf("No mask found for Cityscapes image %q: %v\n", names[i], err)
+			return nil, err
+		}
+	}
+
+	options = append([]FolderOption{WithLabelMap(CityscapesTrainIDs)}, options...)
+	return NewSegmentationFileDataset(names, imageFiles, maskFiles, options...)
+}
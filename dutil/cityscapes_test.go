@@ -0,0 +1,85 @@
+package dutil_test
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+func newTestCityscapes(t *testing.T) (root string) {
+	root, err := ioutil.TempDir("", "cityscapes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"bremen_000000_000019", "aachen_000001_000019", "aachen_000000_000019"} {
+		city := name[:len(name)-14]
+		imageDir := filepath.Join(root, "leftImg8bit", "train", city)
+		maskDir := filepath.Join(root, "gtFine", "train", city)
+		for _, d := range []string{imageDir, maskDir} {
+			if err := os.MkdirAll(d, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		writePNG(t, filepath.Join(imageDir, name+"_leftImg8bit.png"), image.NewRGBA(image.Rect(0, 0, 2, 2)))
+		mask := image.NewGray(image.Rect(0, 0, 2, 2))
+		mask.Pix = []uint8{7, 26, 0, 33}
+		writePNG(t, filepath.Join(maskDir, name+"_gtFine_labelIds.png"), mask)
+		// Other ground truth files are ignored.
+		writePNG(t, filepath.Join(maskDir, name+"_gtFine_color.png"), image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	}
+
+	return root
+}
+
+func TestCityscapesDataset(t *testing.T) {
+	root := newTestCityscapes(t)
+	defer os.RemoveAll(root)
+
+	ds, err := dutil.NewCityscapesDataset(root, "train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"aachen_000000_000019", "aachen_000001_000019", "bremen_000000_000019"}
+	if !reflect.DeepEqual(wantNames, ds.Names()) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", ds.Names())
+	}
+
+	item, err := ds.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{0, 13, 255, 18}
+	got := item.(*dutil.SegmentationSample).Mask.Int64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Keep label IDs.
+	ds, err = dutil.NewCityscapesDataset(root, "train", dutil.WithLabelMap(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err = ds.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []int64{7, 26, 0, 33}
+	got = item.(*dutil.SegmentationSample).Mask.Int64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	if _, err := dutil.NewCityscapesDataset(root, "val"); err == nil {
+		t.Errorf("Expected missing split error.")
+	}
+}
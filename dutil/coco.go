@@ -0,0 +1,400 @@
+package dutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// COCOImage is an image entry of COCO annotation file.
+type COCOImage struct {
+	ID       int64  `json:"id"`
+	FileName string `json:"file_name"`
+	Height   int    `json:"height"`
+	Width    int    `json:"width"`
+}
+
+// COCOCategory is a category entry of COCO annotation file.
+type COCOCategory struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Supercategory string `json:"supercategory"`
+}
+
+// COCOSegmentation is segmentation of an annotation: either polygons
+// (flat lists of x, y coordinates) or RLE (usually for crowd annotations).
+type COCOSegmentation struct {
+	Polygons [][]float64
+	RLE      *RLE
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *COCOSegmentation) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &s.Polygons)
+	}
+
+	s.RLE = new(RLE)
+	return json.Unmarshal(data, s.RLE)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s COCOSegmentation) MarshalJSON() ([]byte, error) {
+	if s.RLE != nil {
+		return json.Marshal(s.RLE)
+	}
+
+	return json.Marshal(s.Polygons)
+}
+
+// COCOAnnotation is an object annotation of COCO annotation file.
+type COCOAnnotation struct {
+	ID           int64            `json:"id"`
+	ImageID      int64            `json:"image_id"`
+	CategoryID   int64            `json:"category_id"`
+	Segmentation COCOSegmentation `json:"segmentation"`
+	Area         float64          `json:"area"`
+	BBox         []float64        `json:"bbox"`
+	IsCrowd      int              `json:"iscrowd"`
+}
+
+// COCO holds content of a COCO annotation file.
+// Ref. https://cocodataset.org/#format-data
+type COCO struct {
+	Images      []COCOImage      `json:"images"`
+	Categories  []COCOCategory   `json:"categories"`
+	Annotations []COCOAnnotation `json:"annotations"`
+}
+
+// LoadCOCO reads a COCO annotation JSON file.
+func LoadCOCO(file string) (*COCO, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var coco COCO
+	if err := json.Unmarshal(data, &coco); err != nil {
+		err = fmt.Errorf("Parsing COCO annotation file %q failed: %v\n", file, err)
+		return nil, err
+	}
+
+	return &coco, nil
+}
+
+// RasterizePolygon fills a polygon given as flat list of x, y coordinates
+// into a binary mask of size h x w (row-major). A pixel is inside if its
+// center is inside the polygon (even-odd rule).
+func RasterizePolygon(polygon []float64, h, w int) []bool {
+	fg := make([]bool, h*w)
+	n := len(polygon) / 2
+	if n < 3 {
+		return fg
+	}
+
+	var xs []float64
+	for y := 0; y < h; y++ {
+		yc := float64(y) + 0.5
+		xs = xs[:0]
+		for i := 0; i < n; i++ {
+			x1, y1 := polygon[2*i], polygon[2*i+1]
+			j := (i + 1) % n
+			x2, y2 := polygon[2*j], polygon[2*j+1]
+			if (y1 <= yc && yc < y2) || (y2 <= yc && yc < y1) {
+				xs = append(xs, x1+(yc-y1)*(x2-x1)/(y2-y1))
+			}
+		}
+		sort.Float64s(xs)
+
+		for k := 0; k+1 < len(xs); k += 2 {
+			// Pixels with center x + 0.5 in [xs[k], xs[k+1]).
+			start := int(math.Max(0, math.Ceil(xs[k]-0.5)))
+			end := int(math.Min(float64(w), math.Ceil(xs[k+1]-0.5)))
+			for x := start; x < end; x++ {
+				fg[y*w+x] = true
+			}
+		}
+	}
+
+	return fg
+}
+
+// AnnotationMask returns binary mask of size h x w (row-major) of an annotation.
+func AnnotationMask(ann COCOAnnotation, h, w int) ([]bool, error) {
+	seg := ann.Segmentation
+	if seg.RLE != nil {
+		if seg.RLE.Height != h || seg.RLE.Width != w {
+			err := fmt.Errorf("RLE size %vx%v mismatched image size %vx%v of annotation %v.\n", seg.RLE.Height, seg.RLE.Width, h, w, ann.ID)
+			return nil, err
+		}
+		return seg.RLE.Decode()
+	}
+
+	fg := make([]bool, h*w)
+	for _, poly := range seg.Polygons {
+		for i, v := range RasterizePolygon(poly, h, w) {
+			fg[i] = fg[i] || v
+		}
+	}
+
+	return fg, nil
+}
+
+// COCOOptions holds optional settings for COCODataset.
+type COCOOptions struct {
+	Categories  []int64         // COCO category IDs to keep, mapped to classes 1, 2, ... in given order. Nil means all categories.
+	CategoryMap map[int64]int64 // explicit mapping of COCO category IDs to classes. Unmapped categories are dropped. Overrides Categories.
+	CrowdIndex  int64           // semantic and instance mask value of crowd regions. Negative means crowd annotations are regular objects.
+	Instances   bool            // whether to return instance ID masks
+	SkipEmpty   bool            // whether to skip images without any kept annotation
+}
+
+type COCOOption func(*COCOOptions)
+
+func NewCOCOOptions(options ...COCOOption) COCOOptions {
+	opts := COCOOptions{
+		Categories:  nil,
+		CategoryMap: nil,
+		CrowdIndex:  255,
+		Instances:   false,
+		SkipEmpty:   false,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithCategories(ids ...int64) COCOOption {
+	return func(o *COCOOptions) {
+		o.Categories = ids
+	}
+}
+
+func WithCategoryMap(m map[int64]int64) COCOOption {
+	return func(o *COCOOptions) {
+		o.CategoryMap = m
+	}
+}
+
+func WithCrowdIndex(crowdIndex int64) COCOOption {
+	return func(o *COCOOptions) {
+		o.CrowdIndex = crowdIndex
+	}
+}
+
+func WithInstances(instances bool) COCOOption {
+	return func(o *COCOOptions) {
+		o.Instances = instances
+	}
+}
+
+func WithSkipEmpty(skipEmpty bool) COCOOption {
+	return func(o *COCOOptions) {
+		o.SkipEmpty = skipEmpty
+	}
+}
+
+// COCODataset is a segmentation dataset of images and COCO annotations.
+// Masks are rasterized from annotations on the fly: semantic mask holds
+// class indices with background 0 and, optionally, instance mask holds
+// instance IDs 1, 2, ... with background 0.
+//
+// Overlapping objects are painted in order of decreasing area so that
+// smaller objects stay visible.
+type COCODataset struct {
+	imageDir    string
+	images      []COCOImage
+	annotations map[int64][]COCOAnnotation // by image ID, sorted by decreasing area
+	classes     map[int64]int64            // COCO category ID to class index
+	opts        COCOOptions
+}
+
+// NewCOCODataset creates a new COCODataset from a COCO annotation file and a
+// directory of images.
+func NewCOCODataset(annotationFile, imageDir string, options ...COCOOption) (*COCODataset, error) {
+	coco, err := LoadCOCO(annotationFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCOCODatasetFrom(coco, imageDir, options...)
+}
+
+// NewCOCODatasetFrom creates a new COCODataset from loaded COCO annotations.
+func NewCOCODatasetFrom(coco *COCO, imageDir string, options ...COCOOption) (*COCODataset, error) {
+	opts := NewCOCOOptions(options...)
+
+	known := make(map[int64]bool, len(coco.Categories))
+	for _, c := range coco.Categories {
+		known[c.ID] = true
+	}
+
+	classes := make(map[int64]int64)
+	switch {
+	case opts.CategoryMap != nil:
+		for id, class := range opts.CategoryMap {
+			classes[id] = class
+		}
+	case opts.Categories != nil:
+		for i, id := range opts.Categories {
+			if !known[id] {
+				err := fmt.Errorf("Unknown category ID: %v\n", id)
+				return nil, err
+			}
+			classes[id] = int64(i + 1)
+		}
+	default:
+		ids := make([]int64, 0, len(coco.Categories))
+		for _, c := range coco.Categories {
+			ids = append(ids, c.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for i, id := range ids {
+			classes[id] = int64(i + 1)
+		}
+	}
+
+	annotations := make(map[int64][]COCOAnnotation)
+	for _, ann := range coco.Annotations {
+		if _, ok := classes[ann.CategoryID]; !ok {
+			continue
+		}
+		annotations[ann.ImageID] = append(annotations[ann.ImageID], ann)
+	}
+	for _, anns := range annotations {
+		sort.SliceStable(anns, func(i, j int) bool { return anns[i].Area > anns[j].Area })
+	}
+
+	var images []COCOImage
+	for _, img := range coco.Images {
+		if opts.SkipEmpty && len(annotations[img.ID]) == 0 {
+			continue
+		}
+		images = append(images, img)
+	}
+
+	return &COCODataset{
+		imageDir:    imageDir,
+		images:      images,
+		annotations: annotations,
+		classes:     classes,
+		opts:        opts,
+	}, nil
+}
+
+// Masks rasterizes annotations of image at idx to semantic and instance
+// masks of size h x w (row-major). Instance IDs follow annotation painting
+// order and skip crowd index, so that crowd regions and objects stay distinct.
+func (ds *COCODataset) Masks(idx int) (semantic, instance []int64, err error) {
+	if idx < 0 || idx >= len(ds.images) {
+		err := fmt.Errorf("Idx is out of range.")
+		return nil, nil, err
+	}
+
+	img := ds.images[idx]
+	h, w := img.Height, img.Width
+	semantic = make([]int64, h*w)
+	instance = make([]int64, h*w)
+
+	var nextInstance int64 = 1
+	for _, ann := range ds.annotations[img.ID] {
+		fg, err := AnnotationMask(ann, h, w)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		crowd := ann.IsCrowd != 0 && ds.opts.CrowdIndex >= 0
+		class := ds.classes[ann.CategoryID]
+		for i, v := range fg {
+			if !v {
+				continue
+			}
+			if crowd {
+				semantic[i] = ds.opts.CrowdIndex
+				instance[i] = ds.opts.CrowdIndex
+				continue
+			}
+			semantic[i] = class
+			instance[i] = nextInstance
+		}
+		if !crowd {
+			nextInstance++
+			if nextInstance == ds.opts.CrowdIndex {
+				nextInstance++
+			}
+		}
+	}
+
+	return semantic, instance, nil
+}
+
+// Item implements Dataset interface. It returns a *SegmentationSample.
+func (ds *COCODataset) Item(idx int) (interface{}, error) {
+	if idx < 0 || idx >= len(ds.images) {
+		err := fmt.Errorf("Idx is out of range.")
+		return nil, err
+	}
+
+	info := ds.images[idx]
+	img, err := DecodeImage(filepath.Join(ds.imageDir, info.FileName))
+	if err != nil {
+		return nil, err
+	}
+	if size := img.Bounds().Size(); size.X != info.Width || size.Y != info.Height {
+		err := fmt.Errorf("Image %q of size %vx%v mismatched annotated size %vx%v.\n", info.FileName, size.Y, size.X, info.Height, info.Width)
+		return nil, err
+	}
+
+	semantic, instance, err := ds.Masks(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := []int64{int64(info.Height), int64(info.Width)}
+	sample := &SegmentationSample{
+		Name:  strings.TrimSuffix(info.FileName, filepath.Ext(info.FileName)),
+		Image: ImageToTensor(img),
+		Mask:  ts.MustOfSlice(semantic).MustView(shape, true),
+	}
+	if ds.opts.Instances {
+		sample.Instance = ts.MustOfSlice(instance).MustView(shape, true)
+	}
+
+	return sample, nil
+}
+
+func (ds *COCODataset) Len() int {
+	return len(ds.images)
+}
+
+// DType implements Dataset interface. Dataset is indexed like a slice of samples.
+func (ds *COCODataset) DType() reflect.Type {
+	return reflect.TypeOf([]*SegmentationSample{})
+}
+
+func (ds *COCODataset) ItemType() reflect.Type {
+	return ds.DType().Elem()
+}
+
+// Images returns image entries in dataset order.
+func (ds *COCODataset) Images() []COCOImage {
+	return ds.images
+}
+
+// Class returns class index of a COCO category ID and whether it's kept.
+func (ds *COCODataset) Class(categoryID int64) (int64, bool) {
+	class, ok := ds.classes[categoryID]
+	return class, ok
+}
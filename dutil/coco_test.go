@@ -0,0 +1,204 @@
+package dutil_test
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+const testCOCO = `{
+  "images": [
+    {"id": 1, "file_name": "a.png", "height": 4, "width": 4},
+    {"id": 2, "file_name": "b.png", "height": 4, "width": 4}
+  ],
+  "categories": [
+    {"id": 5, "name": "cat", "supercategory": "animal"},
+    {"id": 1, "name": "person", "supercategory": "person"}
+  ],
+  "annotations": [
+    {"id": 10, "image_id": 1, "category_id": 1, "segmentation": [[0, 0, 2, 0, 2, 2, 0, 2]], "area": 4, "iscrowd": 0},
+    {"id": 11, "image_id": 1, "category_id": 5, "segmentation": [[2, 2, 4, 2, 4, 4, 2, 4]], "area": 4, "iscrowd": 0},
+    {"id": 12, "image_id": 1, "category_id": 1, "segmentation": {"size": [4, 4], "counts": [2, 2, 12]}, "area": 2, "iscrowd": 1}
+  ]
+}`
+
+func newTestCOCO(t *testing.T) (annFile, imageDir string) {
+	dir, err := ioutil.TempDir("", "coco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	annFile = filepath.Join(dir, "instances.json")
+	if err := ioutil.WriteFile(annFile, []byte(testCOCO), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(dir, "a.png"), image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	writePNG(t, filepath.Join(dir, "b.png"), image.NewRGBA(image.Rect(0, 0, 4, 4)))
+
+	return annFile, dir
+}
+
+func TestRasterizePolygon(t *testing.T) {
+	// Triangle covering pixel centers on and below the diagonal.
+	got := dutil.RasterizePolygon([]float64{0, 0, 3, 3, 0, 3}, 3, 3)
+	want := []bool{
+		false, false, false,
+		true, false, false,
+		true, true, false,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Polygon partly outside image is clipped.
+	got = dutil.RasterizePolygon([]float64{-1, -1, 2, -1, 2, 1, -1, 1}, 3, 3)
+	want = []bool{
+		true, true, false,
+		false, false, false,
+		false, false, false,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestCOCODataset(t *testing.T) {
+	annFile, dir := newTestCOCO(t)
+	defer os.RemoveAll(dir)
+
+	ds, err := dutil.NewCOCODataset(annFile, dir, dutil.WithInstances(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 2 {
+		t.Errorf("Want: %v\n", 2)
+		t.Errorf("Got: %v\n", ds.Len())
+	}
+
+	// Categories sorted by ID: person (1) -> 1, cat (5) -> 2.
+	semantic, instance, err := ds.Masks(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSemantic := []int64{
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+		255, 0, 2, 2,
+		255, 0, 2, 2,
+	}
+	wantInstance := []int64{
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+		255, 0, 2, 2,
+		255, 0, 2, 2,
+	}
+	if !reflect.DeepEqual(wantSemantic, semantic) {
+		t.Errorf("Want: %v\n", wantSemantic)
+		t.Errorf("Got: %v\n", semantic)
+	}
+	if !reflect.DeepEqual(wantInstance, instance) {
+		t.Errorf("Want: %v\n", wantInstance)
+		t.Errorf("Got: %v\n", instance)
+	}
+
+	item, err := ds.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := item.(*dutil.SegmentationSample)
+	if s.Name != "a" {
+		t.Errorf("Want: a\n")
+		t.Errorf("Got: %v\n", s.Name)
+	}
+	if !reflect.DeepEqual(wantSemantic, s.Mask.Int64Values()) {
+		t.Errorf("Want: %v\n", wantSemantic)
+		t.Errorf("Got: %v\n", s.Mask.Int64Values())
+	}
+	if s.Instance == nil {
+		t.Errorf("Want instance mask.")
+	}
+}
+
+func TestCOCODataset_CrowdInstance(t *testing.T) {
+	annFile, dir := newTestCOCO(t)
+	defer os.RemoveAll(dir)
+
+	// Instance ID 2 is reserved for crowd regions.
+	ds, err := dutil.NewCOCODataset(annFile, dir, dutil.WithInstances(true), dutil.WithCrowdIndex(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, instance, err := ds.Masks(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+		2, 0, 3, 3,
+		2, 0, 3, 3,
+	}
+	if !reflect.DeepEqual(want, instance) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", instance)
+	}
+}
+
+func TestCOCODataset_Categories(t *testing.T) {
+	annFile, dir := newTestCOCO(t)
+	defer os.RemoveAll(dir)
+
+	// Keep cat only and skip image without annotations.
+	ds, err := dutil.NewCOCODataset(annFile, dir, dutil.WithCategories(5), dutil.WithSkipEmpty(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Len() != 1 {
+		t.Errorf("Want: %v\n", 1)
+		t.Errorf("Got: %v\n", ds.Len())
+	}
+	semantic, _, err := ds.Masks(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+		0, 0, 1, 1,
+		0, 0, 1, 1,
+	}
+	if !reflect.DeepEqual(want, semantic) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", semantic)
+	}
+
+	// Remap both categories to a single class and treat crowd as regular object.
+	ds, err = dutil.NewCOCODataset(annFile, dir, dutil.WithCategoryMap(map[int64]int64{1: 7, 5: 7}), dutil.WithCrowdIndex(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	semantic, _, err = ds.Masks(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []int64{
+		7, 7, 0, 0,
+		7, 7, 0, 0,
+		7, 0, 7, 7,
+		7, 0, 7, 7,
+	}
+	if !reflect.DeepEqual(want, semantic) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", semantic)
+	}
+
+	if _, err := dutil.NewCOCODataset(annFile, dir, dutil.WithCategories(3)); err == nil {
+		t.Errorf("Expected unknown category error.")
+	}
+}
@@ -0,0 +1,200 @@
+package dutil
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// CollateFunc merges items of a batch loaded from dataset into a batch value,
+// e.g. stacked tensors.
+type CollateFunc func(items []interface{}) (interface{}, error)
+
+// SegmentationBatch is a batch of SegmentationSample stacked along the first dimension.
+type SegmentationBatch struct {
+	Names     []string
+	Images    *ts.Tensor // float tensor of shape [B, C, H, W]
+	Masks     *ts.Tensor // int64 tensor of shape [B, H, W]. Nil if samples have no mask.
+	Instances *ts.Tensor // int64 tensor of shape [B, H, W]. Nil if samples have no instance mask.
+}
+
+// Drop drops tensors of batch.
+func (b *SegmentationBatch) Drop() {
+	for _, x := range []*ts.Tensor{b.Images, b.Masks, b.Instances} {
+		if x != nil {
+			x.MustDrop()
+		}
+	}
+}
+
+// CollateOptions holds optional settings for NewSegmentationCollate.
+type CollateOptions struct {
+	Device    gotch.Device // device to move batch tensors to
+	ImagePad  float64      // image value of padded pixels
+	MaskPad   int64        // mask value of padded pixels, e.g. ignore index
+	DropItems bool         // whether to drop tensors of items after stacking
+}
+
+type CollateOption func(*CollateOptions)
+
+func NewCollateOptions(options ...CollateOption) CollateOptions {
+	opts := CollateOptions{
+		Device:    gotch.CPU,
+		ImagePad:  0,
+		MaskPad:   255,
+		DropItems: false,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithDevice(device gotch.Device) CollateOption {
+	return func(o *CollateOptions) {
+		o.Device = device
+	}
+}
+
+func WithImagePad(pad float64) CollateOption {
+	return func(o *CollateOptions) {
+		o.ImagePad = pad
+	}
+}
+
+func WithMaskPad(pad int64) CollateOption {
+	return func(o *CollateOptions) {
+		o.MaskPad = pad
+	}
+}
+
+func WithDropItems(dropItems bool) CollateOption {
+	return func(o *CollateOptions) {
+		o.DropItems = dropItems
+	}
+}
+
+// padTo pads last 2 dimensions of x at bottom and right to size h x w with
+// constant value. It returns a new tensor.
+func padTo(x *ts.Tensor, h, w int64, value float64) *ts.Tensor {
+	size := x.MustSize()
+	padH, padW := h-size[len(size)-2], w-size[len(size)-1]
+	if padH == 0 && padW == 0 {
+		return x.MustShallowClone()
+	}
+
+	pad := []int64{0, padW, 0, padH}
+	if value == 0 {
+		return x.MustConstantPadNd(pad, false)
+	}
+
+	dtype := x.DType()
+	out := x.MustTotype(gotch.Double, false).MustSubScalar(ts.FloatScalar(value), true)
+	out = out.MustConstantPadNd(pad, true).MustAddScalar(ts.FloatScalar(value), true)
+
+	return out.MustTotype(dtype, true)
+}
+
+// stackPadded pads tensors to the largest height and width and stacks them
+// along a new first dimension on device.
+func stackPadded(xs []*ts.Tensor, h, w int64, value float64, device gotch.Device) *ts.Tensor {
+	padded := make([]ts.Tensor, len(xs))
+	for i, x := range xs {
+		p := padTo(x, h, w, value)
+		padded[i] = *p
+	}
+	stacked := ts.MustStack(padded, 0)
+	for i := range padded {
+		padded[i].MustDrop()
+	}
+
+	return stacked.MustTo(device, true)
+}
+
+// NewSegmentationCollate creates a CollateFunc that stacks *SegmentationSample
+// items into a *SegmentationBatch with images of shape [B, C, H, W] and masks of
+// shape [B, H, W] on a device. Samples of different sizes are padded at bottom
+// and right to the largest height and width. All images must have the same
+// number of channels.
+func NewSegmentationCollate(options ...CollateOption) CollateFunc {
+	opts := NewCollateOptions(options...)
+
+	return func(items []interface{}) (interface{}, error) {
+		if len(items) == 0 {
+			err := fmt.Errorf("Expected at least 1 item to collate.\n")
+			return nil, err
+		}
+
+		samples := make([]*SegmentationSample, len(items))
+		var c, h, w int64
+		for i, item := range items {
+			s, ok := item.(*SegmentationSample)
+			if !ok {
+				err := fmt.Errorf("Expected item of type *SegmentationSample. Got: %T\n", item)
+				return nil, err
+			}
+			if i > 0 && (s.Mask == nil) != (samples[0].Mask == nil) {
+				err := fmt.Errorf("Expected all or none of samples to have mask.\n")
+				return nil, err
+			}
+			if i > 0 && (s.Instance == nil) != (samples[0].Instance == nil) {
+				err := fmt.Errorf("Expected all or none of samples to have instance mask.\n")
+				return nil, err
+			}
+			size := s.Image.MustSize()
+			if len(size) != 3 {
+				err := fmt.Errorf("Expected image of shape [C, H, W]. Got: %v\n", size)
+				return nil, err
+			}
+			if i == 0 {
+				c = size[0]
+			} else if size[0] != c {
+				err := fmt.Errorf("Expected all images to have %v channels. Got: %v\n", c, size[0])
+				return nil, err
+			}
+			if size[1] > h {
+				h = size[1]
+			}
+			if size[2] > w {
+				w = size[2]
+			}
+			samples[i] = s
+		}
+
+		batch := &SegmentationBatch{Names: make([]string, len(samples))}
+		var images, masks, instances []*ts.Tensor
+		for i, s := range samples {
+			batch.Names[i] = s.Name
+			images = append(images, s.Image)
+			if s.Mask != nil {
+				masks = append(masks, s.Mask)
+			}
+			if s.Instance != nil {
+				instances = append(instances, s.Instance)
+			}
+		}
+
+		batch.Images = stackPadded(images, h, w, opts.ImagePad, opts.Device)
+		if len(masks) > 0 {
+			batch.Masks = stackPadded(masks, h, w, float64(opts.MaskPad), opts.Device)
+		}
+		if len(instances) > 0 {
+			batch.Instances = stackPadded(instances, h, w, 0, opts.Device)
+		}
+
+		if opts.DropItems {
+			for _, s := range samples {
+				for _, x := range []*ts.Tensor{s.Image, s.Mask, s.Instance} {
+					if x != nil {
+						x.MustDrop()
+					}
+				}
+			}
+		}
+
+		return batch, nil
+	}
+}
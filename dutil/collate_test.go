@@ -0,0 +1,149 @@
+package dutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+func newCollateSample(name string, h, w int64, value int64) *dutil.SegmentationSample {
+	n := h * w
+	img := make([]float32, n)
+	mask := make([]int64, n)
+	for i := range mask {
+		img[i] = float32(value)
+		mask[i] = value
+	}
+
+	return &dutil.SegmentationSample{
+		Name:  name,
+		Image: ts.MustOfSlice(img).MustView([]int64{1, h, w}, true),
+		Mask:  ts.MustOfSlice(mask).MustView([]int64{h, w}, true),
+	}
+}
+
+func TestSegmentationCollate(t *testing.T) {
+	collate := dutil.NewSegmentationCollate()
+	items := []interface{}{
+		newCollateSample("a", 2, 2, 1),
+		newCollateSample("b", 1, 3, 2),
+	}
+
+	out, err := collate(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := out.(*dutil.SegmentationBatch)
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(want, batch.Names) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", batch.Names)
+	}
+	if want := []int64{2, 1, 2, 3}; !reflect.DeepEqual(want, batch.Images.MustSize()) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", batch.Images.MustSize())
+	}
+	if batch.Instances != nil {
+		t.Errorf("Want nil instances.")
+	}
+
+	// Padded at bottom and right.
+	wantImages := []float64{
+		1, 1, 0,
+		1, 1, 0,
+
+		2, 2, 2,
+		0, 0, 0,
+	}
+	if got := batch.Images.Float64Values(); !reflect.DeepEqual(wantImages, got) {
+		t.Errorf("Want: %v\n", wantImages)
+		t.Errorf("Got: %v\n", got)
+	}
+	wantMasks := []int64{
+		1, 1, 255,
+		1, 1, 255,
+
+		2, 2, 2,
+		255, 255, 255,
+	}
+	if got := batch.Masks.Int64Values(); !reflect.DeepEqual(wantMasks, got) {
+		t.Errorf("Want: %v\n", wantMasks)
+		t.Errorf("Got: %v\n", got)
+	}
+	if want := []int64{2, 2, 3}; !reflect.DeepEqual(want, batch.Masks.MustSize()) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", batch.Masks.MustSize())
+	}
+
+	// Custom pad values
+	collate = dutil.NewSegmentationCollate(dutil.WithImagePad(-1), dutil.WithMaskPad(0))
+	out, err = collate(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch = out.(*dutil.SegmentationBatch)
+	if got := batch.Images.Float64Values(); got[2] != -1 || batch.Masks.Int64Values()[2] != 0 {
+		t.Errorf("Want padded values -1 and 0. Got: %v and %v\n", got[2], batch.Masks.Int64Values()[2])
+	}
+}
+
+func TestSegmentationCollate_Invalid(t *testing.T) {
+	collate := dutil.NewSegmentationCollate()
+
+	if _, err := collate(nil); err == nil {
+		t.Errorf("Expected empty batch error.")
+	}
+	if _, err := collate([]interface{}{1, 2}); err == nil {
+		t.Errorf("Expected invalid item type error.")
+	}
+
+	noMask := newCollateSample("b", 2, 2, 1)
+	noMask.Mask = nil
+	if _, err := collate([]interface{}{newCollateSample("a", 2, 2, 1), noMask}); err == nil {
+		t.Errorf("Expected missing mask error.")
+	}
+
+	rgb := newCollateSample("b", 2, 2, 1)
+	rgb.Image = ts.MustOnes([]int64{3, 2, 2}, gotch.Float, gotch.CPU)
+	if _, err := collate([]interface{}{newCollateSample("a", 2, 2, 1), rgb}); err == nil {
+		t.Errorf("Expected channel mismatch error.")
+	}
+}
+
+func TestDataLoader_Collate(t *testing.T) {
+	samples := []*dutil.SegmentationSample{
+		newCollateSample("a", 2, 2, 0),
+		newCollateSample("b", 2, 2, 1),
+		newCollateSample("c", 2, 2, 2),
+	}
+	data, err := dutil.NewSliceDataset(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := dutil.NewBatchSampler(3, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewDataLoader(data, s, dutil.WithCollate(dutil.NewSegmentationCollate()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes [][]int64
+	for dl.HasNext() {
+		out, err := dl.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, out.(*dutil.SegmentationBatch).Images.MustSize())
+	}
+	want := [][]int64{{2, 1, 2, 2}, {1, 1, 2, 2}}
+	if !reflect.DeepEqual(want, sizes) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", sizes)
+	}
+}
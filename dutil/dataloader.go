@@ -1,20 +1,106 @@
 package dutil
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
 )
 
 // DataLoader combines a dataset and a sampler and provides
 // an iterable over the given dataset.
 type DataLoader struct {
 	dataset   Dataset
-	indexes   []int // order of samples in dataset for interation.
+	sampler   Sampler
+	epoch     int
+	indexes   []int // dataset indexes in order of iteration, drawn from sampler
 	batchSize int
 	currIdx   int
+	opts      LoaderOptions
+	prefetch  *prefetcher // nil unless workers are running
 }
 
-func NewDataLoader(data Dataset, s Sampler) (*DataLoader, error) {
+// LoaderOptions holds optional settings for DataLoader.
+type LoaderOptions struct {
+	Collate  CollateFunc     // if set, items of a batch are merged with it. Otherwise, a slice of items is returned.
+	Workers  int             // number of goroutines loading batches. 0 means loading on caller's goroutine.
+	Prefetch int             // maximum number of batches loaded ahead by workers
+	Context  context.Context // cancelling it stops workers and makes Next return its error
+	Seed     int64           // base seed of per-epoch sampling (see EpochSeed). Only used if set by WithSeed.
+
+	seeded bool // whether Seed is set
+}
+
+type LoaderOption func(*LoaderOptions)
+
+func NewLoaderOptions(options ...LoaderOption) LoaderOptions {
+	opts := LoaderOptions{
+		Collate:  nil,
+		Workers:  0,
+		Prefetch: 2,
+		Context:  context.Background(),
+		Seed:     0,
+		seeded:   false,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+// WithCollate sets function to merge items of a batch, e.g. NewSegmentationCollate().
+func WithCollate(collate CollateFunc) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Collate = collate
+	}
+}
+
+// WithWorkers sets number of goroutines loading batches concurrently.
+func WithWorkers(workers int) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Workers = workers
+	}
+}
+
+// WithPrefetch sets maximum number of batches loaded ahead by workers.
+func WithPrefetch(prefetch int) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Prefetch = prefetch
+	}
+}
+
+func WithContext(ctx context.Context) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Context = ctx
+	}
+}
+
+// WithSeed sets base seed so that each epoch is shuffled differently but
+// reproducibly. Any seed including 0 and negative ones is valid. It has effect
+// with samplers implementing SeededSampler. Without it, sampling is unseeded.
+func WithSeed(seed int64) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Seed = seed
+		o.seeded = true
+	}
+}
+
+// EpochSeed derives sampling seed of an epoch from a base seed. Seeds of
+// different epochs are decorrelated by SplitMix64 mixing.
+// Ref. https://prng.di.unimi.it/splitmix64.c
+func EpochSeed(seed int64, epoch int) int64 {
+	z := uint64(seed) + uint64(epoch+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+
+	// Non-negative so that it can be used as a base seed too.
+	return int64(z >> 1)
+}
+
+func NewDataLoader(data Dataset, s Sampler, options ...LoaderOption) (*DataLoader, error) {
 	dkind, err := checkDKind(data)
 	if err != nil {
 		return nil, err
@@ -33,12 +119,25 @@ func NewDataLoader(data Dataset, s Sampler) (*DataLoader, error) {
 		}
 	}
 
-	return &DataLoader{
+	opts := NewLoaderOptions(options...)
+	if opts.Workers < 0 || opts.Prefetch < 1 {
+		err := fmt.Errorf("Expected non-negative number of workers and positive prefetch. Got: %v and %v\n", opts.Workers, opts.Prefetch)
+		return nil, err
+	}
+	if opts.Context == nil {
+		err := fmt.Errorf("Expected non-nil context.\n")
+		return nil, err
+	}
+
+	dl := &DataLoader{
 		dataset:   data,
-		indexes:   s.Sample(),
+		sampler:   s,
 		batchSize: s.BatchSize(),
-		currIdx:   0,
-	}, nil
+		opts:      opts,
+	}
+	dl.StartEpoch(0)
+
+	return dl, nil
 }
 
 func checkDKind(data Dataset) (DatasetKind, error) {
@@ -62,49 +161,225 @@ func checkDKind(data Dataset) (DatasetKind, error) {
 }
 
 // Next acts as iterator to return next sample(s) from dataset.
+//
+// With workers (see WithWorkers), batches are loaded concurrently ahead of
+// time but still returned in sampling order. If loading fails, the error is
+// returned, workers are stopped and the next call retries the failed batch.
 func (dl *DataLoader) Next() (interface{}, error) {
 	if !dl.HasNext() {
 		err := fmt.Errorf("Next Error: no more item to iterate.\n")
 		return nil, err
 	}
+	if err := dl.opts.Context.Err(); err != nil {
+		return nil, err
+	}
 
-	// Non-batching
-	if dl.batchSize == 1 {
-		item, err := dl.dataset.Item(dl.currIdx)
-		if err != nil {
-			return nil, err
-		}
+	if dl.opts.Workers > 0 {
+		return dl.nextPrefetched()
+	}
 
-		dl.currIdx += 1
-		return item, nil
+	end := dl.batchEnd(dl.currIdx)
+	batch, err := dl.loadBatch(dl.currIdx, end)
+	if err != nil {
+		return nil, err
 	}
 
-	// Batch sampling
-	items := reflect.MakeSlice(reflect.SliceOf(dl.dataset.ItemType()), 0, dl.dataset.Len())
-	nextIndex := dl.currIdx + dl.batchSize
+	dl.currIdx = end
+	return batch, nil
+}
 
+// batchEnd returns end position (exclusive) of batch starting at start.
+func (dl *DataLoader) batchEnd(start int) int {
+	end := start + dl.batchSize
 	// NOTE. length of indexes can be shorter than dataset length
-	if nextIndex >= len(dl.indexes) {
-		nextIndex = len(dl.indexes)
+	if end >= len(dl.indexes) {
+		end = len(dl.indexes)
+	}
+
+	return end
+}
+
+// loadBatch loads items at positions [start, end) of sampled indexes. It returns
+// a single item if batch size is 1, a collated batch if collate function is
+// set or a slice of items otherwise.
+func (dl *DataLoader) loadBatch(start, end int) (interface{}, error) {
+	// Non-batching
+	if dl.batchSize == 1 && dl.opts.Collate == nil {
+		return dl.dataset.Item(dl.indexes[start])
 	}
-	for i := dl.currIdx; i < nextIndex; i++ {
-		item, err := dl.dataset.Item(i)
+
+	if dl.opts.Collate != nil {
+		items := make([]interface{}, 0, end-start)
+		for i := start; i < end; i++ {
+			item, err := dl.dataset.Item(dl.indexes[i])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return dl.opts.Collate(items)
+	}
+
+	// Batch sampling
+	items := reflect.MakeSlice(reflect.SliceOf(dl.dataset.ItemType()), 0, end-start)
+	for i := start; i < end; i++ {
+		item, err := dl.dataset.Item(dl.indexes[i])
 		if err != nil {
 			return nil, err
 		}
 		items = reflect.Append(items, reflect.ValueOf(item))
 	}
 
-	dl.currIdx = nextIndex
 	return items.Interface(), nil
 }
 
+type batchResult struct {
+	batch interface{}
+	err   error
+}
+
+// prefetcher loads batches with worker goroutines. Each batch has its own
+// result channel so that batches are consumed in order regardless of which
+// worker finishes first.
+type prefetcher struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ends    []int              // end positions of batches
+	results []chan batchResult // buffered results of batches
+	slots   chan struct{}      // bounds number of loaded but not consumed batches
+	next    int                // next batch to consume
+	wg      sync.WaitGroup
+}
+
+// startWorkers starts loading batches from current position.
+func (dl *DataLoader) startWorkers() {
+	ctx, cancel := context.WithCancel(dl.opts.Context)
+	p := &prefetcher{
+		ctx:    ctx,
+		cancel: cancel,
+		slots:  make(chan struct{}, dl.opts.Prefetch),
+	}
+	var starts []int
+	for start := dl.currIdx; start < len(dl.indexes); start = dl.batchEnd(start) {
+		starts = append(starts, start)
+		p.ends = append(p.ends, dl.batchEnd(start))
+		p.results = append(p.results, make(chan batchResult, 1))
+	}
+
+	jobs := make(chan int)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(jobs)
+		for k := range starts {
+			select {
+			case p.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < dl.opts.Workers; w++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for k := range jobs {
+				batch, err := dl.loadBatch(starts[k], p.ends[k])
+				p.results[k] <- batchResult{batch, err}
+			}
+		}()
+	}
+
+	dl.prefetch = p
+}
+
+// stopWorkers stops workers and drops loaded batches that were not consumed.
+func (dl *DataLoader) stopWorkers() {
+	p := dl.prefetch
+	if p == nil {
+		return
+	}
+
+	p.cancel()
+	p.wg.Wait()
+	for k := p.next; k < len(p.results); k++ {
+		select {
+		case r := <-p.results[k]:
+			if b, ok := r.batch.(interface{ Drop() }); ok {
+				b.Drop()
+			}
+		default:
+		}
+	}
+	dl.prefetch = nil
+}
+
+// nextPrefetched returns next batch loaded by workers.
+func (dl *DataLoader) nextPrefetched() (interface{}, error) {
+	if dl.prefetch == nil {
+		dl.startWorkers()
+	}
+
+	p := dl.prefetch
+	select {
+	case r := <-p.results[p.next]:
+		<-p.slots
+		p.next++
+		if r.err != nil {
+			dl.stopWorkers()
+			return nil, r.err
+		}
+		dl.currIdx = p.ends[p.next-1]
+		return r.batch, nil
+
+	case <-p.ctx.Done():
+		dl.stopWorkers()
+		return nil, dl.opts.Context.Err()
+	}
+}
+
 // HasNext returns whether there is a next item in the iteration.
 func (dl *DataLoader) HasNext() bool {
 	return dl.currIdx < len(dl.indexes)
 }
 
-// Reset reset index to start position.
+// StartEpoch starts iteration of an epoch with newly sampled indexes. With a
+// base seed (see WithSeed), sampling of an epoch is reproducible:
+// StartEpoch(n) gives the same order on every run. Epoch is also passed to
+// datasets implementing EpochDataset.
+func (dl *DataLoader) StartEpoch(epoch int) {
+	dl.stopWorkers()
+	if d, ok := dl.dataset.(EpochDataset); ok {
+		d.SetEpoch(epoch)
+	}
+	if s, ok := dl.sampler.(SeededSampler); ok && dl.opts.seeded {
+		s.Seed(EpochSeed(dl.opts.Seed, epoch))
+	}
+	dl.indexes = dl.sampler.Sample()
+	dl.epoch = epoch
+	dl.currIdx = 0
+}
+
+// Epoch returns current epoch.
+func (dl *DataLoader) Epoch() int {
+	return dl.epoch
+}
+
+// Reset reset index to start position of current epoch keeping sampled order.
+// Use StartEpoch to sample a new order.
 func (dl *DataLoader) Reset() {
+	dl.stopWorkers()
 	dl.currIdx = 0
 }
+
+// Close stops workers and drops prefetched batches. DataLoader can be
+// iterated again after Reset as long as its context is not cancelled.
+func (dl *DataLoader) Close() {
+	dl.stopWorkers()
+}
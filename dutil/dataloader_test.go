@@ -5,7 +5,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/iseg/dutil"
 )
 
 func TestNewDataLoader(t *testing.T) {
@@ -42,3 +42,321 @@ func TestDataLoader_Next(t *testing.T) {
 		t.Errorf("Got: %v\n", got)
 	}
 }
+
+// fixedSampler draws indexes in a given order.
+type fixedSampler struct {
+	indexes   []int
+	batchSize int
+}
+
+func (s *fixedSampler) Sample() []int {
+	return s.indexes
+}
+
+func (s *fixedSampler) BatchSize() int {
+	return s.batchSize
+}
+
+// loadAll iterates data loader and returns batches of items of a SliceDataset of []int.
+func loadAll(t *testing.T, dl *dutil.DataLoader) [][]int {
+	t.Helper()
+	var batches [][]int
+	for dl.HasNext() {
+		batch, err := dl.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch b := batch.(type) {
+		case int:
+			batches = append(batches, []int{b})
+		case []int:
+			batches = append(batches, b)
+		default:
+			t.Fatalf("Unexpected batch type: %T\n", batch)
+		}
+	}
+
+	return batches
+}
+
+func flatten(batches [][]int) []int {
+	var items []int
+	for _, b := range batches {
+		items = append(items, b...)
+	}
+
+	return items
+}
+
+func TestDataLoader_SamplerOrder(t *testing.T) {
+	data, err := dutil.NewSliceDataset([]int{10, 11, 12, 13, 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{0, 2} {
+		s := &fixedSampler{indexes: []int{3, 0, 4, 4, 1}, batchSize: 2}
+		dl, err := dutil.NewDataLoader(data, s, dutil.WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := [][]int{{13, 10}, {14, 14}, {11}}
+		got := loadAll(t, dl)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+		}
+		dl.Close()
+	}
+}
+
+func TestDataLoader_Shuffle(t *testing.T) {
+	n := 100
+	data, err := dutil.NewSliceDataset(seq(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := dutil.NewBatchSampler(n, 10, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewDataLoader(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := flatten(loadAll(t, dl))
+	if isDup(got) || len(got) != n {
+		t.Errorf("Want permutation of %v items. Got: %v\n", n, got)
+	}
+	if reflect.DeepEqual(seq(n), got) {
+		t.Errorf("Expected shuffled items. Got: %v\n", got)
+	}
+
+	rs, err := dutil.NewRandomSampler(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err = dutil.NewDataLoader(data, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = flatten(loadAll(t, dl))
+	if isDup(got) || len(got) != n || reflect.DeepEqual(seq(n), got) {
+		t.Errorf("Expected shuffled permutation. Got: %v\n", got)
+	}
+}
+
+func TestDataLoader_Replacement(t *testing.T) {
+	data, err := dutil.NewSliceDataset(seq(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := dutil.NewRandomSampler(3, dutil.WithReplacement(true), dutil.WithSize(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewDataLoader(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := flatten(loadAll(t, dl))
+	if len(got) != 20 || !isDup(got) {
+		t.Errorf("Want 20 items with duplicates. Got: %v\n", got)
+	}
+
+	// Without replacement: a random subset
+	data, err = dutil.NewSliceDataset(seq(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = dutil.NewRandomSampler(50, dutil.WithSize(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err = dutil.NewDataLoader(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = flatten(loadAll(t, dl))
+	if len(got) != 20 || isDup(got) {
+		t.Errorf("Want 20 distinct items. Got: %v\n", got)
+	}
+}
+
+func TestDataLoader_DropLast(t *testing.T) {
+	data, err := dutil.NewSliceDataset(seq(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dropLast bool
+		shuffle  bool
+		sizes    []int
+	}{
+		{true, false, []int{3, 3, 3}},
+		{false, false, []int{3, 3, 3, 1}},
+		{true, true, []int{3, 3, 3}},
+		{false, true, []int{3, 3, 3, 1}},
+	}
+	for _, tt := range tests {
+		s, err := dutil.NewBatchSampler(10, 3, tt.dropLast, tt.shuffle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dl, err := dutil.NewDataLoader(data, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batches := loadAll(t, dl)
+		var sizes []int
+		for _, b := range batches {
+			sizes = append(sizes, len(b))
+		}
+		if !reflect.DeepEqual(tt.sizes, sizes) {
+			t.Errorf("Want: %v\n", tt.sizes)
+			t.Errorf("Got: %v\n", sizes)
+		}
+		if items := flatten(batches); isDup(items) {
+			t.Errorf("Unexpected duplicated items. Got: %v\n", items)
+		}
+		if !tt.shuffle && !reflect.DeepEqual(seq(len(flatten(batches))), flatten(batches)) {
+			t.Errorf("Want: %v\n", seq(len(flatten(batches))))
+			t.Errorf("Got: %v\n", flatten(batches))
+		}
+	}
+}
+
+func newEpochLoader(t *testing.T, seed int64) *dutil.DataLoader {
+	t.Helper()
+	data, err := dutil.NewSliceDataset(seq(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := dutil.NewBatchSampler(50, 5, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewDataLoader(data, s, dutil.WithSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dl
+}
+
+func TestDataLoader_StartEpoch(t *testing.T) {
+	dl1 := newEpochLoader(t, 42)
+	dl2 := newEpochLoader(t, 42)
+
+	var epochs [][]int
+	for epoch := 0; epoch < 3; epoch++ {
+		dl1.StartEpoch(epoch)
+		dl2.StartEpoch(epoch)
+		want := flatten(loadAll(t, dl1))
+		got := flatten(loadAll(t, dl2))
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+		}
+		if dl1.Epoch() != epoch {
+			t.Errorf("Want: %v\n", epoch)
+			t.Errorf("Got: %v\n", dl1.Epoch())
+		}
+		epochs = append(epochs, want)
+	}
+	if reflect.DeepEqual(epochs[0], epochs[1]) || reflect.DeepEqual(epochs[1], epochs[2]) {
+		t.Errorf("Expected different order in each epoch. Got: %v\n", epochs)
+	}
+
+	// Reset keeps order, StartEpoch of a past epoch reproduces it.
+	dl1.Reset()
+	if got := flatten(loadAll(t, dl1)); !reflect.DeepEqual(epochs[2], got) {
+		t.Errorf("Want: %v\n", epochs[2])
+		t.Errorf("Got: %v\n", got)
+	}
+	dl1.StartEpoch(1)
+	if got := flatten(loadAll(t, dl1)); !reflect.DeepEqual(epochs[1], got) {
+		t.Errorf("Want: %v\n", epochs[1])
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Different base seed
+	dl3 := newEpochLoader(t, 7)
+	dl3.StartEpoch(1)
+	if got := flatten(loadAll(t, dl3)); reflect.DeepEqual(epochs[1], got) {
+		t.Errorf("Expected different order with different seed. Got: %v\n", got)
+	}
+
+	// Negative base seed is as valid as others.
+	dl4 := newEpochLoader(t, -1)
+	dl5 := newEpochLoader(t, -1)
+	dl4.StartEpoch(1)
+	dl5.StartEpoch(1)
+	if want, got := flatten(loadAll(t, dl4)), flatten(loadAll(t, dl5)); !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestEpochSeed(t *testing.T) {
+	seen := make(map[int64]bool)
+	for _, seed := range []int64{0, 1, 42} {
+		for epoch := 0; epoch < 100; epoch++ {
+			s := dutil.EpochSeed(seed, epoch)
+			if s < 0 || seen[s] {
+				t.Fatalf("Want distinct non-negative seeds. Got: %v for seed %v and epoch %v\n", s, seed, epoch)
+			}
+			seen[s] = true
+		}
+	}
+	if dutil.EpochSeed(42, 3) != dutil.EpochSeed(42, 3) {
+		t.Errorf("Expected deterministic seed.")
+	}
+}
+
+// epochDataset returns its index plus 100 times epoch set by DataLoader as item.
+type epochDataset struct {
+	n     int
+	epoch int
+}
+
+func (ds *epochDataset) Item(idx int) (interface{}, error) {
+	return ds.epoch*100 + idx, nil
+}
+
+func (ds *epochDataset) Len() int {
+	return ds.n
+}
+
+func (ds *epochDataset) DType() reflect.Type {
+	return reflect.TypeOf([]int{})
+}
+
+func (ds *epochDataset) ItemType() reflect.Type {
+	return ds.DType().Elem()
+}
+
+func (ds *epochDataset) SetEpoch(epoch int) {
+	ds.epoch = epoch
+}
+
+func TestDataLoader_SetEpoch(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		data := &epochDataset{n: 4}
+		dl, err := dutil.NewDataLoader(data, dutil.NewSequentialSampler(4), dutil.WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dl.StartEpoch(3)
+		want := []int{300, 301, 302, 303}
+		if got := flatten(loadAll(t, dl)); !reflect.DeepEqual(want, got) {
+			t.Errorf("Workers %v - Want: %v\n", workers, want)
+			t.Errorf("Workers %v - Got: %v\n", workers, got)
+		}
+		dl.Close()
+	}
+}
@@ -3,6 +3,7 @@ package dutil
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // Dataset represents a set of samples and
@@ -15,6 +16,13 @@ type Dataset interface {
 	Len() int
 }
 
+// EpochDataset is a Dataset whose items depend on training epoch, e.g. with
+// random augmentation. DataLoader sets epoch on every StartEpoch.
+type EpochDataset interface {
+	Dataset
+	SetEpoch(epoch int)
+}
+
 type DatasetKind int
 
 const (
@@ -66,7 +74,7 @@ func (ds *SliceDataset) ItemType() reflect.Type {
 type MapDataset struct {
 	// data map[string]interface{}
 	data interface{}
-	keys []string // keys to access elements in map
+	keys []string // sorted keys to access elements in map
 }
 
 // NewMapDataset creates a new MapDataset.
@@ -92,6 +100,8 @@ func NewMapDataset(data interface{}) (*MapDataset, error) {
 		key := mapIter.Key().Interface()
 		keys = append(keys, key.(string))
 	}
+	// Map iteration order is random. Sort keys so that index of an item is fixed.
+	sort.Strings(keys)
 
 	return &MapDataset{
 		data: data,
@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/iseg/dutil"
 )
 
 func TestNewSliceDataset(t *testing.T) {
@@ -107,8 +107,9 @@ func TestMapDataset_Item(t *testing.T) {
 		t.Error(err)
 	}
 
+	// Items are ordered by key.
 	want := 3
-	got, err := ds.Item(0)
+	got, err := ds.Item(1)
 	if err != nil {
 		t.Error(err)
 	}
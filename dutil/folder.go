@@ -0,0 +1,238 @@
+package dutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// SegmentationSample is a sample of a segmentation dataset.
+type SegmentationSample struct {
+	Name  string     // file name stem
+	Image *ts.Tensor // float tensor of shape [C, H, W] with values in [0, 1]
+	Mask  *ts.Tensor // int64 tensor of shape [H, W] of class indices
+
+	Instance *ts.Tensor // optional int64 tensor of shape [H, W] of instance IDs (0 = none)
+}
+
+// FolderOptions holds optional settings for SegmentationFolderDataset.
+type FolderOptions struct {
+	Extensions []string        // image file extensions to include (case-insensitive)
+	MaskSuffix string          // suffix of mask file stem, e.g. "_mask" for "xxx_mask.png"
+	Palette    *Palette        // if set, mask colors are converted to classes. Otherwise, masks are single-channel.
+	LabelMap   map[int64]int64 // if set, mask values are remapped. Unmapped values are kept.
+}
+
+type FolderOption func(*FolderOptions)
+
+func NewFolderOptions(options ...FolderOption) FolderOptions {
+	opts := FolderOptions{
+		Extensions: []string{".png", ".jpg", ".jpeg", ".tif", ".tiff"},
+		MaskSuffix: "",
+		Palette:    nil,
+		LabelMap:   nil,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithExtensions(extensions ...string) FolderOption {
+	return func(o *FolderOptions) {
+		o.Extensions = extensions
+	}
+}
+
+func WithMaskSuffix(suffix string) FolderOption {
+	return func(o *FolderOptions) {
+		o.MaskSuffix = suffix
+	}
+}
+
+func WithPalette(p *Palette) FolderOption {
+	return func(o *FolderOptions) {
+		o.Palette = p
+	}
+}
+
+func WithLabelMap(m map[int64]int64) FolderOption {
+	return func(o *FolderOptions) {
+		o.LabelMap = m
+	}
+}
+
+// SegmentationFolderDataset is a dataset of images and masks stored as
+// files in two directories. An image is paired with the mask of the same
+// file name stem, e.g. "images/001.jpg" and "masks/001.png".
+type SegmentationFolderDataset struct {
+	names      []string // file name stems
+	imageFiles []string
+	maskFiles  []string
+	opts       FolderOptions
+}
+
+// listStems returns files in dir with one of extensions keyed by file name stem.
+func listStems(dir string, extensions []string) (map[string]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		name := info.Name()
+		ext := filepath.Ext(name)
+		for _, e := range extensions {
+			if strings.EqualFold(ext, e) {
+				stem := strings.TrimSuffix(name, ext)
+				if _, ok := files[stem]; ok {
+					err := fmt.Errorf("Duplicate file name stem %q in %q.\n", stem, dir)
+					return nil, err
+				}
+				files[stem] = filepath.Join(dir, name)
+				break
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// NewSegmentationFolderDataset creates a new SegmentationFolderDataset from
+// image and mask directories. It returns an error if any image has no mask.
+// Masks without images are ignored.
+func NewSegmentationFolderDataset(imageDir, maskDir string, options ...FolderOption) (*SegmentationFolderDataset, error) {
+	opts := NewFolderOptions(options...)
+
+	images, err := listStems(imageDir, opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	masks, err := listStems(maskDir, opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	sameDir := filepath.Clean(imageDir) == filepath.Clean(maskDir)
+	names := make([]string, 0, len(images))
+	for stem := range images {
+		if sameDir && opts.MaskSuffix != "" && strings.HasSuffix(stem, opts.MaskSuffix) {
+			continue // a mask in shared directory
+		}
+		names = append(names, stem)
+	}
+	sort.Strings(names)
+
+	imageFiles := make([]string, len(names))
+	maskFiles := make([]string, len(names))
+	var missing []string
+	for i, stem := range names {
+		imageFiles[i] = images[stem]
+		maskFile, ok := masks[stem+opts.MaskSuffix]
+		if !ok {
+			missing = append(missing, stem)
+			continue
+		}
+		maskFiles[i] = maskFile
+	}
+	if len(missing) > 0 {
+		err := fmt.Errorf("No mask found in %q for %v image(s): %v\n", maskDir, len(missing), missing)
+		return nil, err
+	}
+
+	return NewSegmentationFileDataset(names, imageFiles, maskFiles, options...)
+}
+
+// NewSegmentationFileDataset creates a new SegmentationFolderDataset from
+// lists of sample names, image files and corresponding mask files.
+// Extensions and MaskSuffix options are not used.
+func NewSegmentationFileDataset(names, imageFiles, maskFiles []string, options ...FolderOption) (*SegmentationFolderDataset, error) {
+	if len(imageFiles) != len(names) || len(maskFiles) != len(names) {
+		err := fmt.Errorf("Expected the same number of names, images and masks. Got: %v, %v and %v\n", len(names), len(imageFiles), len(maskFiles))
+		return nil, err
+	}
+
+	return &SegmentationFolderDataset{
+		names:      names,
+		imageFiles: imageFiles,
+		maskFiles:  maskFiles,
+		opts:       NewFolderOptions(options...),
+	}, nil
+}
+
+// Item implements Dataset interface. It returns a *SegmentationSample.
+func (ds *SegmentationFolderDataset) Item(idx int) (interface{}, error) {
+	if idx < 0 || idx >= len(ds.names) {
+		err := fmt.Errorf("Idx is out of range.")
+		return nil, err
+	}
+
+	img, err := DecodeImage(ds.imageFiles[idx])
+	if err != nil {
+		return nil, err
+	}
+	maskImg, err := DecodeImage(ds.maskFiles[idx])
+	if err != nil {
+		return nil, err
+	}
+	if img.Bounds().Size() != maskImg.Bounds().Size() {
+		err := fmt.Errorf("Image and mask sizes mismatched for %q. Got: %v and %v\n", ds.names[idx], img.Bounds().Size(), maskImg.Bounds().Size())
+		return nil, err
+	}
+
+	var (
+		labels []int64
+		h, w   int
+	)
+	if ds.opts.Palette != nil {
+		labels, h, w, err = ds.opts.Palette.Labels(maskImg)
+	} else {
+		labels, h, w, err = maskValues(maskImg)
+	}
+	if err != nil {
+		err = fmt.Errorf("Invalid mask %q: %v", ds.maskFiles[idx], err)
+		return nil, err
+	}
+	if ds.opts.LabelMap != nil {
+		for i, v := range labels {
+			if mapped, ok := ds.opts.LabelMap[v]; ok {
+				labels[i] = mapped
+			}
+		}
+	}
+
+	return &SegmentationSample{
+		Name:  ds.names[idx],
+		Image: ImageToTensor(img),
+		Mask:  ts.MustOfSlice(labels).MustView([]int64{int64(h), int64(w)}, true),
+	}, nil
+}
+
+func (ds *SegmentationFolderDataset) Len() int {
+	return len(ds.names)
+}
+
+// DType implements Dataset interface. Dataset is indexed like a slice of samples.
+func (ds *SegmentationFolderDataset) DType() reflect.Type {
+	return reflect.TypeOf([]*SegmentationSample{})
+}
+
+func (ds *SegmentationFolderDataset) ItemType() reflect.Type {
+	return ds.DType().Elem()
+}
+
+// Names returns names of samples (e.g. file name stems) in dataset order.
+func (ds *SegmentationFolderDataset) Names() []string {
+	return ds.names
+}
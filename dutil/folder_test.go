@@ -0,0 +1,138 @@
+package dutil_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/chai2010/tiff"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+func writePNG(t *testing.T, file string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTIFF(t *testing.T, file string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := tiff.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestFolder creates image and mask directories with 2 samples of size 2x3.
+func newTestFolder(t *testing.T) (root string) {
+	root, err := ioutil.TempDir("", "folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"images", "masks"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rgb := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	rgb.Set(0, 0, color.NRGBA{R: 255, G: 0, B: 51, A: 255})
+	gray := image.NewGray(image.Rect(0, 0, 3, 2))
+	gray.SetGray(2, 1, color.Gray{Y: 255})
+
+	mask := image.NewGray(image.Rect(0, 0, 3, 2))
+	mask.SetGray(1, 0, color.Gray{Y: 1})
+	mask.SetGray(2, 1, color.Gray{Y: 2})
+
+	writePNG(t, filepath.Join(root, "images", "b.png"), rgb)
+	writeTIFF(t, filepath.Join(root, "images", "a.tif"), gray)
+	writePNG(t, filepath.Join(root, "masks", "a_mask.png"), mask)
+	writePNG(t, filepath.Join(root, "masks", "b_mask.png"), mask)
+	writePNG(t, filepath.Join(root, "masks", "c_mask.png"), mask) // no image
+
+	return root
+}
+
+func TestSegmentationFolderDataset(t *testing.T) {
+	root := newTestFolder(t)
+	defer os.RemoveAll(root)
+
+	ds, err := dutil.NewSegmentationFolderDataset(filepath.Join(root, "images"), filepath.Join(root, "masks"), dutil.WithMaskSuffix("_mask"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantNames := []string{"a", "b"}
+	if !reflect.DeepEqual(wantNames, ds.Names()) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", ds.Names())
+	}
+
+	item, err := ds.Item(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := item.(*dutil.SegmentationSample)
+
+	wantImageSize := []int64{3, 2, 3}
+	if !reflect.DeepEqual(wantImageSize, s.Image.MustSize()) {
+		t.Errorf("Want: %v\n", wantImageSize)
+		t.Errorf("Got: %v\n", s.Image.MustSize())
+	}
+	// R, G, B of pixel (0, 0)
+	vals := s.Image.Float64Values()
+	gotPixel := []float64{vals[0], vals[6], vals[12]}
+	wantPixel := []float64{1, 0, 0.2}
+	for i := range wantPixel {
+		if diff := gotPixel[i] - wantPixel[i]; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("Want: %v\n", wantPixel)
+			t.Errorf("Got: %v\n", gotPixel)
+			break
+		}
+	}
+
+	wantMask := []int64{0, 1, 0, 0, 0, 2}
+	if !reflect.DeepEqual(wantMask, s.Mask.Int64Values()) {
+		t.Errorf("Want: %v\n", wantMask)
+		t.Errorf("Got: %v\n", s.Mask.Int64Values())
+	}
+
+	// Gray TIFF image has a single channel.
+	item, err = ds.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantImageSize = []int64{1, 2, 3}
+	gotImageSize := item.(*dutil.SegmentationSample).Image.MustSize()
+	if !reflect.DeepEqual(wantImageSize, gotImageSize) {
+		t.Errorf("Want: %v\n", wantImageSize)
+		t.Errorf("Got: %v\n", gotImageSize)
+	}
+}
+
+func TestSegmentationFolderDataset_MissingMask(t *testing.T) {
+	root := newTestFolder(t)
+	defer os.RemoveAll(root)
+
+	// Masks are named "xxx_mask.png" so no mask matches without suffix.
+	_, err := dutil.NewSegmentationFolderDataset(filepath.Join(root, "images"), filepath.Join(root, "masks"))
+	if err == nil {
+		t.Errorf("Expected missing mask error.")
+	}
+}
@@ -0,0 +1,124 @@
+package dutil
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"os"
+
+	_ "github.com/chai2010/tiff" // register TIFF decoder
+	"github.com/sugarme/gotch/ts"
+)
+
+// DecodeImage reads and decodes an image file. Supported formats are PNG, JPEG and TIFF.
+func DecodeImage(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		err = fmt.Errorf("Decoding image %q failed: %v\n", file, err)
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// isGray returns whether image has a single channel.
+func isGray(img image.Image) bool {
+	m := img.ColorModel()
+	return m == color.GrayModel || m == color.Gray16Model
+}
+
+// imageValues returns pixel values of img in range [0, 1] in channel-first
+// order. Gray images have 1 channel, others have 3 (RGB) channels.
+func imageValues(img image.Image) (vals []float32, c, h, w int) {
+	b := img.Bounds()
+	h, w = b.Dy(), b.Dx()
+	c = 3
+	if isGray(img) {
+		c = 1
+	}
+
+	plane := h * w
+	vals = make([]float32, c*plane)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			vals[i] = float32(r) / 0xffff
+			if c == 3 {
+				vals[plane+i] = float32(g) / 0xffff
+				vals[2*plane+i] = float32(bl) / 0xffff
+			}
+		}
+	}
+
+	return vals, c, h, w
+}
+
+// maskValues returns label values of a single-channel mask image: palette
+// indices of paletted images and intensities of gray images. Colour images
+// are accepted only if all channels are equal.
+func maskValues(img image.Image) (vals []int64, h, w int, err error) {
+	b := img.Bounds()
+	h, w = b.Dy(), b.Dx()
+	vals = make([]int64, h*w)
+
+	switch m := img.(type) {
+	case *image.Paletted:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				vals[y*w+x] = int64(m.ColorIndexAt(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				vals[y*w+x] = int64(m.GrayAt(b.Min.X+x, b.Min.Y+y).Y)
+			}
+		}
+	case *image.Gray16:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				vals[y*w+x] = int64(m.Gray16At(b.Min.X+x, b.Min.Y+y).Y)
+			}
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+				if c.R != c.G || c.R != c.B {
+					err = fmt.Errorf("Expected single-channel mask. Got color %v at (%v, %v).\n", c, x, y)
+					return nil, 0, 0, err
+				}
+				vals[y*w+x] = int64(c.R)
+			}
+		}
+	}
+
+	return vals, h, w, nil
+}
+
+// ImageToTensor converts an image to a float tensor of shape [C, H, W] with
+// values in range [0, 1]. Gray images have 1 channel, others have 3 (RGB) channels.
+func ImageToTensor(img image.Image) *ts.Tensor {
+	vals, c, h, w := imageValues(img)
+	return ts.MustOfSlice(vals).MustView([]int64{int64(c), int64(h), int64(w)}, true)
+}
+
+// MaskToTensor converts a single-channel mask image to an int64 tensor of
+// shape [H, W]. Paletted images give palette indices and gray images give intensities.
+func MaskToTensor(img image.Image) (*ts.Tensor, error) {
+	vals, h, w, err := maskValues(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.MustOfSlice(vals).MustView([]int64{int64(h), int64(w)}, true), nil
+}
@@ -3,7 +3,6 @@ package dutil
 import (
 	"fmt"
 	"math/rand"
-	"sort"
 )
 
 // KFold represents a struct helper to
@@ -12,7 +11,9 @@ type KFold struct {
 	n       int
 	nfolds  int
 	shuffle bool
-	//seed int
+	rng     *rand.Rand
+	seed    int64
+	seeded  bool
 }
 
 // Fold represents a partitions with
@@ -23,8 +24,12 @@ type Fold struct {
 }
 
 type KFoldOptions struct {
-	NFolds  int  // number of folds
-	Shuffle bool // whether suffling before splitting
+	NFolds  int        // number of folds
+	Shuffle bool       // whether suffling before splitting
+	Rand    *rand.Rand // random generator for shuffling. Nil means seeding with current time.
+	Seed    int64      // seed of a new generator at every Split. Only used if set by WithKFoldSeed.
+
+	seeded bool // whether Seed is set
 }
 
 type KFoldOption func(*KFoldOptions)
@@ -33,6 +38,9 @@ func NewKFoldOptions(options ...KFoldOption) KFoldOptions {
 	opts := KFoldOptions{
 		NFolds:  5,
 		Shuffle: false,
+		Rand:    nil,
+		Seed:    0,
+		seeded:  false,
 	}
 
 	for _, o := range options {
@@ -54,6 +62,25 @@ func WithKFoldShuffle(shuffle bool) KFoldOption {
 	}
 }
 
+// WithKFoldSeed makes shuffled splits reproducible: every Split shuffles
+// with a new generator seeded with seed, so repeated calls give the same folds.
+func WithKFoldSeed(seed int64) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.Rand = nil
+		o.Seed = seed
+		o.seeded = true
+	}
+}
+
+// WithKFoldRand sets random generator used for shuffling. Its state is shared
+// across calls to Split, so each call gives different folds.
+func WithKFoldRand(r *rand.Rand) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.Rand = r
+		o.seeded = false
+	}
+}
+
 // NewKFold creates a new KFold struct.
 func NewKFold(n int, opt ...KFoldOption) (*KFold, error) {
 	opts := NewKFoldOptions(opt...)
@@ -72,22 +99,29 @@ func NewKFold(n int, opt ...KFoldOption) (*KFold, error) {
 		n:       n,
 		nfolds:  opts.NFolds,
 		shuffle: opts.Shuffle,
+		rng:     opts.Rand,
+		seed:    opts.Seed,
+		seeded:  opts.seeded,
 	}, nil
 }
 
+// Split splits samples into train and test sets of each fold. Without
+// shuffling, folds are consecutive and the last n % nfolds samples are left out.
 func (kf *KFold) Split() []Fold {
 	odd := kf.n % kf.nfolds
 	nsamples := kf.n - odd
 	fsize := nsamples / kf.nfolds
-	var indices []int
-
-	allIndices := rand.Perm(kf.n)
-	// Drop last odd-time elements
-	indices = allIndices[:nsamples]
 
-	if !kf.shuffle {
-		sort.Ints(indices)
+	allIndices := intRange(kf.n)
+	if kf.shuffle {
+		rng := kf.rng
+		if kf.seeded {
+			rng = rand.New(rand.NewSource(kf.seed))
+		}
+		allIndices = samplerRand(rng).Perm(kf.n)
 	}
+	// Drop last odd-time elements
+	indices := allIndices[:nsamples]
 
 	// Split to train, test sets
 	var (
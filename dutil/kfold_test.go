@@ -1,9 +1,11 @@
 package dutil_test
 
 import (
+	"math/rand"
+	"reflect"
 	"testing"
 
-	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/iseg/dutil"
 )
 
 func TestNewKFold(t *testing.T) {
@@ -54,3 +56,54 @@ func TestKFold_Split(t *testing.T) {
 		}
 	}
 }
+
+func TestKFold_SplitSeed(t *testing.T) {
+	split := func(opts ...dutil.KFoldOption) []dutil.Fold {
+		opts = append([]dutil.KFoldOption{dutil.WithNFolds(3), dutil.WithKFoldShuffle(true)}, opts...)
+		kf, err := dutil.NewKFold(20, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kf.Split()
+	}
+
+	want := split(dutil.WithKFoldSeed(42))
+	got := split(dutil.WithKFoldSeed(42))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+	got = split(dutil.WithKFoldRand(rand.New(rand.NewSource(42))))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+	if got := split(dutil.WithKFoldSeed(7)); reflect.DeepEqual(want, got) {
+		t.Errorf("Expected different splits with different seed.")
+	}
+
+	// Repeated splits of a seeded KFold are the same.
+	kf, err := dutil.NewKFold(20, dutil.WithNFolds(3), dutil.WithKFoldShuffle(true), dutil.WithKFoldSeed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := kf.Split(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+	if got := kf.Split(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Without shuffling, folds are consecutive and last odd samples left out.
+	kf, err = dutil.NewKFold(11, dutil.WithNFolds(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFold := dutil.Fold{Train: []int{0, 1, 2, 6, 7, 8}, Test: []int{3, 4, 5}}
+	if got := kf.Split()[1]; !reflect.DeepEqual(wantFold, got) {
+		t.Errorf("Want: %v\n", wantFold)
+		t.Errorf("Got: %v\n", got)
+	}
+}
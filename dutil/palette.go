@@ -0,0 +1,262 @@
+package dutil
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// PaletteOptions holds optional settings for Palette.
+type PaletteOptions struct {
+	IgnoreIndex int64       // class of unknown colors. Negative means unknown colors are errors.
+	IgnoreColor color.Color // color of ignore index when saving masks
+}
+
+type PaletteOption func(*PaletteOptions)
+
+func NewPaletteOptions(options ...PaletteOption) PaletteOptions {
+	opts := PaletteOptions{
+		IgnoreIndex: -1,
+		IgnoreColor: color.White,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+// WithIgnoreIndex maps unknown colors to ignore index instead of returning an error.
+func WithIgnoreIndex(ignoreIndex int64) PaletteOption {
+	return func(o *PaletteOptions) {
+		o.IgnoreIndex = ignoreIndex
+	}
+}
+
+func WithIgnoreColor(c color.Color) PaletteOption {
+	return func(o *PaletteOptions) {
+		o.IgnoreColor = c
+	}
+}
+
+// Palette maps mask colors to class indices and back. Class i has color i.
+type Palette struct {
+	colors []color.Color
+	index  map[[3]uint8]int64
+	opts   PaletteOptions
+}
+
+func rgbKey(c color.Color) [3]uint8 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return [3]uint8{n.R, n.G, n.B}
+}
+
+// NewPalette creates a new Palette from class colors. Alpha is ignored when
+// matching colors. It returns an error if colors are duplicated or ignore
+// color is color of another class.
+func NewPalette(colors []color.Color, options ...PaletteOption) (*Palette, error) {
+	opts := NewPaletteOptions(options...)
+	if len(colors) > 256 {
+		err := fmt.Errorf("Expected at most 256 colors. Got: %v\n", len(colors))
+		return nil, err
+	}
+
+	index := make(map[[3]uint8]int64, len(colors)+1)
+	for i, c := range colors {
+		k := rgbKey(c)
+		if j, ok := index[k]; ok {
+			err := fmt.Errorf("Duplicate color %v of classes %v and %v.\n", k, j, i)
+			return nil, err
+		}
+		index[k] = int64(i)
+	}
+	if opts.IgnoreIndex >= 0 {
+		k := rgbKey(opts.IgnoreColor)
+		if j, ok := index[k]; ok && j != opts.IgnoreIndex {
+			err := fmt.Errorf("Ignore color %v is color of class %v.\n", k, j)
+			return nil, err
+		}
+		index[k] = opts.IgnoreIndex
+	}
+
+	return &Palette{
+		colors: colors,
+		index:  index,
+		opts:   opts,
+	}, nil
+}
+
+// VOCColors returns first n colors of PASCAL VOC color map (n <= 256).
+// Ref. http://host.robots.ox.ac.uk/pascal/VOC/voc2012/htmldoc/devkit_doc.html
+func VOCColors(n int) []color.Color {
+	colors := make([]color.Color, n)
+	for i := 0; i < n; i++ {
+		var r, g, b uint8
+		c := i
+		for j := 0; j < 8; j++ {
+			r |= uint8(c&1) << (7 - j)
+			g |= uint8((c>>1)&1) << (7 - j)
+			b |= uint8((c>>2)&1) << (7 - j)
+			c >>= 3
+		}
+		colors[i] = color.RGBA{R: r, G: g, B: b, A: 255}
+	}
+
+	return colors
+}
+
+// Len returns number of classes.
+func (p *Palette) Len() int {
+	return len(p.colors)
+}
+
+// Color returns color of class.
+func (p *Palette) Color(class int64) (color.Color, error) {
+	switch {
+	case class >= 0 && class < int64(len(p.colors)):
+		return p.colors[class], nil
+	case p.opts.IgnoreIndex >= 0 && class == p.opts.IgnoreIndex:
+		return p.opts.IgnoreColor, nil
+	default:
+		err := fmt.Errorf("Class %v out of palette range [0, %v).\n", class, len(p.colors))
+		return nil, err
+	}
+}
+
+// Class returns class index of color. Unknown colors give ignore index or an error.
+func (p *Palette) Class(c color.Color) (int64, error) {
+	if class, ok := p.index[rgbKey(c)]; ok {
+		return class, nil
+	}
+	if p.opts.IgnoreIndex >= 0 {
+		return p.opts.IgnoreIndex, nil
+	}
+
+	err := fmt.Errorf("Unknown color %v.\n", rgbKey(c))
+	return -1, err
+}
+
+// Labels converts a mask image to class indices of size h x w.
+//
+// For paletted images (e.g. PASCAL VOC PNGs), each palette entry is looked up
+// once, so palette order of image does not need to match class order.
+func (p *Palette) Labels(img image.Image) (labels []int64, h, w int, err error) {
+	b := img.Bounds()
+	h, w = b.Dy(), b.Dx()
+	labels = make([]int64, h*w)
+
+	if m, ok := img.(*image.Paletted); ok {
+		lut := make([]int64, len(m.Palette))
+		lutErr := make([]error, len(m.Palette))
+		for i, c := range m.Palette {
+			lut[i], lutErr[i] = p.Class(c)
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := m.ColorIndexAt(b.Min.X+x, b.Min.Y+y)
+				if lutErr[i] != nil {
+					err = fmt.Errorf("Invalid mask pixel at (%v, %v): %v", x, y, lutErr[i])
+					return nil, 0, 0, err
+				}
+				labels[y*w+x] = lut[i]
+			}
+		}
+
+		return labels, h, w, nil
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			class, err := p.Class(img.At(b.Min.X+x, b.Min.Y+y))
+			if err != nil {
+				err = fmt.Errorf("Invalid mask pixel at (%v, %v): %v", x, y, err)
+				return nil, 0, 0, err
+			}
+			labels[y*w+x] = class
+		}
+	}
+
+	return labels, h, w, nil
+}
+
+// Image converts class indices of size h x w to a paletted image. Ignore
+// index is drawn with ignore color.
+func (p *Palette) Image(labels []int64, h, w int) (*image.Paletted, error) {
+	pal := make(color.Palette, len(p.colors), len(p.colors)+1)
+	copy(pal, p.colors)
+	ignoreIdx := -1
+	if p.opts.IgnoreIndex >= 0 {
+		if len(pal) >= 256 {
+			err := fmt.Errorf("No palette entry left for ignore color.\n")
+			return nil, err
+		}
+		ignoreIdx = len(pal)
+		pal = append(pal, p.opts.IgnoreColor)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), pal)
+	for i, class := range labels {
+		switch {
+		case class >= 0 && class < int64(len(p.colors)):
+			img.Pix[i] = uint8(class)
+		case ignoreIdx >= 0 && class == p.opts.IgnoreIndex:
+			img.Pix[i] = uint8(ignoreIdx)
+		default:
+			err := fmt.Errorf("Class %v at (%v, %v) out of palette range [0, %v).\n", class, i%w, i/w, len(p.colors))
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+// MaskToTensor converts a mask image to an int64 tensor of class indices of shape [H, W].
+func (p *Palette) MaskToTensor(img image.Image) (*ts.Tensor, error) {
+	labels, h, w, err := p.Labels(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.MustOfSlice(labels).MustView([]int64{int64(h), int64(w)}, true), nil
+}
+
+// TensorToImage converts a mask tensor of class indices of shape [H, W]
+// (e.g. argmax of prediction) to a paletted image.
+func (p *Palette) TensorToImage(mask *ts.Tensor) (*image.Paletted, error) {
+	size := mask.MustSize()
+	if len(size) != 2 {
+		err := fmt.Errorf("Expected mask of shape [H, W]. Got: %v\n", size)
+		return nil, err
+	}
+	m := mask.MustTotype(gotch.Int64, false).MustTo(gotch.CPU, true)
+	labels := m.Int64Values()
+	m.MustDrop()
+
+	return p.Image(labels, int(size[0]), int(size[1]))
+}
+
+// SaveMask saves a mask tensor of class indices of shape [H, W] to file as paletted PNG.
+func (p *Palette) SaveMask(file string, mask *ts.Tensor) error {
+	img, err := p.TensorToImage(mask)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
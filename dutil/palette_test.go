@@ -0,0 +1,129 @@
+package dutil_test
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+func TestVOCColors(t *testing.T) {
+	want := []color.Color{
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{128, 0, 0, 255},
+		color.RGBA{0, 128, 0, 255},
+		color.RGBA{128, 128, 0, 255},
+	}
+	got := dutil.VOCColors(4)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// VOC void color
+	wantVoid := color.RGBA{224, 224, 192, 255}
+	gotVoid := dutil.VOCColors(256)[255]
+	if !reflect.DeepEqual(wantVoid, gotVoid) {
+		t.Errorf("Want: %v\n", wantVoid)
+		t.Errorf("Got: %v\n", gotVoid)
+	}
+}
+
+func TestPalette_Labels(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, red)
+	img.Set(1, 0, green)
+	img.Set(0, 1, green)
+	img.Set(1, 1, blue) // unknown
+
+	p, err := dutil.NewPalette([]color.Color{red, green})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := p.Labels(img); err == nil {
+		t.Errorf("Expected unknown color error.")
+	}
+
+	p, err = dutil.NewPalette([]color.Color{red, green}, dutil.WithIgnoreIndex(255))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{0, 1, 1, 255}
+	got, _, _, err := p.Labels(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	if _, err := dutil.NewPalette([]color.Color{red, color.White}, dutil.WithIgnoreIndex(255)); err == nil {
+		t.Errorf("Expected ignore color collision error.")
+	}
+
+	// Paletted image with palette order different from class order.
+	pimg := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{blue, green, red})
+	pimg.Pix = []uint8{2, 1, 1, 0}
+	got, _, _, err = p.Labels(pimg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestPalette_SaveMask(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := dutil.NewPalette(dutil.VOCColors(3), dutil.WithIgnoreIndex(255))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{0, 1, 2, 255, 2, 1}
+	mask := ts.MustOfSlice(want).MustView([]int64{2, 3}, true)
+	file := filepath.Join(dir, "pred.png")
+	if err := p.SaveMask(file, mask); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := dutil.DecodeImage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.Paletted); !ok {
+		t.Errorf("Want paletted PNG. Got: %T\n", img)
+	}
+	got, err := p.MaskToTensor(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got.Int64Values()) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got.Int64Values())
+	}
+
+	// Class out of palette
+	mask = ts.MustOfSlice([]int64{0, 3}).MustView([]int64{1, 2}, true)
+	if err := p.SaveMask(file, mask); err == nil {
+		t.Errorf("Expected out of palette range error.")
+	}
+}
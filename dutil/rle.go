@@ -0,0 +1,364 @@
+package dutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// NOTE. Both RLE conventions below scan pixels in column-major order, i.e.
+// top to bottom, then left to right.
+
+// KaggleRLEEncode encodes a binary mask of size h x w (row-major) to a Kaggle
+// RLE string of space-separated pairs of 1-indexed start position and run
+// length of foreground pixels in column-major order. Empty mask gives "".
+// Ref. https://www.kaggle.com/c/airbus-ship-detection/overview/evaluation
+func KaggleRLEEncode(fg []bool, h, w int) string {
+	var (
+		sb    strings.Builder
+		start int
+		run   int
+	)
+	writeRun := func() {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.Itoa(start))
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.Itoa(run))
+	}
+
+	pos := 0
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			pos++
+			if fg[y*w+x] {
+				if run == 0 {
+					start = pos
+				}
+				run++
+				continue
+			}
+			if run > 0 {
+				writeRun()
+				run = 0
+			}
+		}
+	}
+	if run > 0 {
+		writeRun()
+	}
+
+	return sb.String()
+}
+
+// KaggleRLEDecode decodes a Kaggle RLE string (see KaggleRLEEncode) to a
+// binary mask of size h x w (row-major).
+func KaggleRLEDecode(rle string, h, w int) ([]bool, error) {
+	fg := make([]bool, h*w)
+	fields := strings.Fields(rle)
+	if len(fields)%2 != 0 {
+		err := fmt.Errorf("Expected RLE of start and length pairs. Got %v numbers.\n", len(fields))
+		return nil, err
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		start, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(fields[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if start < 1 || length < 0 || start-1+length > h*w {
+			err := fmt.Errorf("RLE run (%v, %v) out of mask of size %vx%v.\n", start, length, h, w)
+			return nil, err
+		}
+		for pos := start - 1; pos < start-1+length; pos++ {
+			y, x := pos%h, pos/h
+			fg[y*w+x] = true
+		}
+	}
+
+	return fg, nil
+}
+
+// maskForeground returns nonzero pixels of a mask tensor of shape [H, W].
+func maskForeground(mask *ts.Tensor) (fg []bool, h, w int) {
+	size := mask.MustSize()
+	if len(size) != 2 {
+		log.Fatalf("Expected mask of shape [H, W]. Got: %v\n", size)
+	}
+	h, w = int(size[0]), int(size[1])
+
+	m := mask.MustTotype(gotch.Double, false).MustTo(gotch.CPU, true)
+	vals := m.Float64Values()
+	m.MustDrop()
+
+	fg = make([]bool, len(vals))
+	for i, v := range vals {
+		fg[i] = v != 0
+	}
+
+	return fg, h, w
+}
+
+// foregroundTensor converts a binary mask of size h x w to an int64 tensor of shape [H, W].
+func foregroundTensor(fg []bool, h, w int) *ts.Tensor {
+	vals := make([]int64, len(fg))
+	for i, v := range fg {
+		if v {
+			vals[i] = 1
+		}
+	}
+
+	return ts.MustOfSlice(vals).MustView([]int64{int64(h), int64(w)}, true)
+}
+
+// MaskToKaggleRLE encodes nonzero pixels of a mask tensor of shape [H, W] to
+// a Kaggle RLE string (see KaggleRLEEncode).
+func MaskToKaggleRLE(mask *ts.Tensor) string {
+	fg, h, w := maskForeground(mask)
+	return KaggleRLEEncode(fg, h, w)
+}
+
+// KaggleRLEToMask decodes a Kaggle RLE string to a binary int64 mask tensor of shape [H, W].
+func KaggleRLEToMask(rle string, h, w int) (*ts.Tensor, error) {
+	fg, err := KaggleRLEDecode(rle, h, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return foregroundTensor(fg, h, w), nil
+}
+
+// RLE is COCO run-length encoding of a binary mask: lengths of alternating
+// runs of background and foreground pixels in column-major order, starting
+// with background.
+//
+// In JSON, it's encoded as COCO compressed RLE `{"size": [h, w], "counts": "..."}`.
+// Both compressed (string) and uncompressed (list) counts are accepted when decoding.
+// Ref. https://github.com/cocodataset/cocoapi/blob/master/common/maskApi.c
+type RLE struct {
+	Height int
+	Width  int
+	Counts []int
+}
+
+// EncodeRLE encodes a binary mask of size h x w (row-major) to COCO RLE.
+func EncodeRLE(fg []bool, h, w int) *RLE {
+	r := &RLE{Height: h, Width: w}
+	var (
+		run  int
+		prev bool // background first
+	)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			v := fg[y*w+x]
+			if v != prev {
+				r.Counts = append(r.Counts, run)
+				run = 0
+				prev = v
+			}
+			run++
+		}
+	}
+	r.Counts = append(r.Counts, run)
+
+	return r
+}
+
+// Decode decodes RLE to a binary mask of size Height x Width (row-major).
+func (r *RLE) Decode() ([]bool, error) {
+	h, w := r.Height, r.Width
+	fg := make([]bool, h*w)
+	pos := 0
+	for i, n := range r.Counts {
+		if n < 0 || pos+n > h*w {
+			err := fmt.Errorf("RLE counts exceed mask of size %vx%v.\n", h, w)
+			return nil, err
+		}
+		if i%2 == 1 {
+			for p := pos; p < pos+n; p++ {
+				fg[(p%h)*w+p/h] = true
+			}
+		}
+		pos += n
+	}
+
+	return fg, nil
+}
+
+// Area returns number of foreground pixels.
+func (r *RLE) Area() int {
+	var area int
+	for i := 1; i < len(r.Counts); i += 2 {
+		area += r.Counts[i]
+	}
+
+	return area
+}
+
+// CompressedCounts returns counts in COCO compressed string format.
+func (r *RLE) CompressedCounts() string {
+	var sb strings.Builder
+	for i, n := range r.Counts {
+		x := int64(n)
+		if i > 2 {
+			x -= int64(r.Counts[i-2])
+		}
+		more := true
+		for more {
+			c := x & 0x1f
+			x >>= 5
+			if c&0x10 != 0 {
+				more = x != -1
+			} else {
+				more = x != 0
+			}
+			if more {
+				c |= 0x20
+			}
+			sb.WriteByte(byte(c + 48))
+		}
+	}
+
+	return sb.String()
+}
+
+// ParseCompressedRLE parses COCO compressed RLE counts of a mask of size h x w.
+func ParseCompressedRLE(counts string, h, w int) (*RLE, error) {
+	r := &RLE{Height: h, Width: w}
+	p := 0
+	for p < len(counts) {
+		var (
+			x    int64
+			k    uint
+			more = true
+		)
+		for more {
+			if p >= len(counts) {
+				err := fmt.Errorf("Truncated compressed RLE counts.\n")
+				return nil, err
+			}
+			c := int64(counts[p]) - 48
+			x |= (c & 0x1f) << (5 * k)
+			more = c&0x20 != 0
+			p++
+			k++
+			if !more && c&0x10 != 0 {
+				x |= -1 << (5 * k)
+			}
+		}
+		if m := len(r.Counts); m > 2 {
+			x += int64(r.Counts[m-2])
+		}
+		r.Counts = append(r.Counts, int(x))
+	}
+
+	return r, nil
+}
+
+type rleJSON struct {
+	Size   []int           `json:"size"`
+	Counts json.RawMessage `json:"counts"`
+}
+
+// MarshalJSON implements json.Marshaler using compressed counts.
+func (r *RLE) MarshalJSON() ([]byte, error) {
+	counts, err := json.Marshal(r.CompressedCounts())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(rleJSON{
+		Size:   []int{r.Height, r.Width},
+		Counts: counts,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Counts can be either compressed
+// string or list of numbers.
+func (r *RLE) UnmarshalJSON(data []byte) error {
+	var v rleJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Size) != 2 {
+		err := fmt.Errorf("Expected RLE size of [h, w]. Got: %v\n", v.Size)
+		return err
+	}
+	h, w := v.Size[0], v.Size[1]
+
+	var compressed string
+	if err := json.Unmarshal(v.Counts, &compressed); err == nil {
+		parsed, err := ParseCompressedRLE(compressed, h, w)
+		if err != nil {
+			return err
+		}
+		*r = *parsed
+		return nil
+	}
+
+	var counts []int
+	if err := json.Unmarshal(v.Counts, &counts); err != nil {
+		err = fmt.Errorf("Expected RLE counts of string or list of numbers: %v\n", err)
+		return err
+	}
+	*r = RLE{Height: h, Width: w, Counts: counts}
+
+	return nil
+}
+
+// MaskToRLE encodes nonzero pixels of a mask tensor of shape [H, W] to COCO RLE.
+func MaskToRLE(mask *ts.Tensor) *RLE {
+	fg, h, w := maskForeground(mask)
+	return EncodeRLE(fg, h, w)
+}
+
+// ToTensor decodes RLE to a binary int64 mask tensor of shape [H, W].
+func (r *RLE) ToTensor() (*ts.Tensor, error) {
+	fg, err := r.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return foregroundTensor(fg, r.Height, r.Width), nil
+}
+
+// WriteSubmissionCSV writes a Kaggle-style submission CSV of image IDs and
+// their encoded masks (e.g. from MaskToKaggleRLE).
+//
+// columns: Optional (default=["ImageId", "EncodedPixels"]). Header names.
+func WriteSubmissionCSV(w io.Writer, ids, encodings []string, columns ...string) error {
+	if len(ids) != len(encodings) {
+		err := fmt.Errorf("Expected the same number of IDs and encodings. Got: %v and %v\n", len(ids), len(encodings))
+		return err
+	}
+	if len(columns) == 0 {
+		columns = []string{"ImageId", "EncodedPixels"}
+	}
+	if len(columns) != 2 {
+		err := fmt.Errorf("Expected 2 column names. Got: %v\n", columns)
+		return err
+	}
+
+	df := dataframe.New(
+		series.New(ids, series.String, columns[0]),
+		series.New(encodings, series.String, columns[1]),
+	)
+	if df.Err != nil {
+		return df.Err
+	}
+
+	return df.WriteCSV(w)
+}
@@ -0,0 +1,171 @@
+package dutil_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// 3x4 mask (row-major):
+//
+//	0 1 1 0
+//	0 1 0 0
+//	1 1 0 1
+var (
+	rleMask = []bool{
+		false, true, true, false,
+		false, true, false, false,
+		true, true, false, true,
+	}
+	rleKaggle = "3 5 12 1" // column-major, 1-indexed
+	rleCounts = []int{2, 5, 4, 1}
+)
+
+func TestKaggleRLE(t *testing.T) {
+	got := dutil.KaggleRLEEncode(rleMask, 3, 4)
+	if got != rleKaggle {
+		t.Errorf("Want: %q\n", rleKaggle)
+		t.Errorf("Got: %q\n", got)
+	}
+
+	mask, err := dutil.KaggleRLEDecode(rleKaggle, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rleMask, mask) {
+		t.Errorf("Want: %v\n", rleMask)
+		t.Errorf("Got: %v\n", mask)
+	}
+
+	if got := dutil.KaggleRLEEncode(make([]bool, 12), 3, 4); got != "" {
+		t.Errorf("Want empty RLE. Got: %q\n", got)
+	}
+
+	for _, invalid := range []string{"3", "0 2", "12 2", "a 1"} {
+		if _, err := dutil.KaggleRLEDecode(invalid, 3, 4); err == nil {
+			t.Errorf("Expected error for invalid RLE %q.\n", invalid)
+		}
+	}
+}
+
+func TestKaggleRLE_Tensor(t *testing.T) {
+	mask := ts.MustOfSlice([]int64{0, 1, 1, 0, 0, 1, 0, 0, 1, 1, 0, 1}).MustView([]int64{3, 4}, true)
+	got := dutil.MaskToKaggleRLE(mask)
+	if got != rleKaggle {
+		t.Errorf("Want: %q\n", rleKaggle)
+		t.Errorf("Got: %q\n", got)
+	}
+
+	decoded, err := dutil.KaggleRLEToMask(got, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mask.Int64Values(), decoded.Int64Values()) {
+		t.Errorf("Want: %v\n", mask.Int64Values())
+		t.Errorf("Got: %v\n", decoded.Int64Values())
+	}
+}
+
+func TestRLE(t *testing.T) {
+	r := dutil.EncodeRLE(rleMask, 3, 4)
+	if !reflect.DeepEqual(rleCounts, r.Counts) {
+		t.Errorf("Want: %v\n", rleCounts)
+		t.Errorf("Got: %v\n", r.Counts)
+	}
+	if r.Area() != 6 {
+		t.Errorf("Want: %v\n", 6)
+		t.Errorf("Got: %v\n", r.Area())
+	}
+
+	mask, err := r.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rleMask, mask) {
+		t.Errorf("Want: %v\n", rleMask)
+		t.Errorf("Got: %v\n", mask)
+	}
+
+	// Mask starting with foreground has a leading zero count.
+	r = dutil.EncodeRLE([]bool{true, false}, 2, 1)
+	if want := []int{0, 1, 1}; !reflect.DeepEqual(want, r.Counts) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", r.Counts)
+	}
+}
+
+func TestRLE_Compressed(t *testing.T) {
+	tests := []struct {
+		counts     []int
+		compressed string
+	}{
+		{[]int{2, 3, 4}, "234"},
+		{[]int{0, 5, 1, 2}, "051M"}, // negative delta
+		{[]int{3, 100, 2, 40, 1}, "3T32TNO"},
+	}
+
+	for _, tt := range tests {
+		r := &dutil.RLE{Height: 10, Width: 20, Counts: tt.counts}
+		got := r.CompressedCounts()
+		if got != tt.compressed {
+			t.Errorf("Want: %q\n", tt.compressed)
+			t.Errorf("Got: %q\n", got)
+		}
+
+		parsed, err := dutil.ParseCompressedRLE(tt.compressed, 10, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tt.counts, parsed.Counts) {
+			t.Errorf("Want: %v\n", tt.counts)
+			t.Errorf("Got: %v\n", parsed.Counts)
+		}
+	}
+}
+
+func TestRLE_JSON(t *testing.T) {
+	r := &dutil.RLE{Height: 3, Width: 4, Counts: rleCounts}
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"size":[3,4],"counts":"254L"}`
+	if string(data) != want {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", string(data))
+	}
+
+	for _, input := range []string{want, `{"size":[3,4],"counts":[2,5,4,1]}`} {
+		var got dutil.RLE
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*r, got) {
+			t.Errorf("Want: %v\n", *r)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+}
+
+func TestWriteSubmissionCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := dutil.WriteSubmissionCSV(&buf, []string{"a.jpg", "b.jpg"}, []string{rleKaggle, "1 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "ImageId,EncodedPixels\na.jpg,3 5 12 1\nb.jpg,1 2\n"
+	if buf.String() != want {
+		t.Errorf("Want: %q\n", want)
+		t.Errorf("Got: %q\n", buf.String())
+	}
+
+	if err := dutil.WriteSubmissionCSV(&buf, []string{"a.jpg"}, nil); err == nil {
+		t.Errorf("Expected mismatched length error.")
+	}
+}
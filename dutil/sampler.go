@@ -14,6 +14,22 @@ type Sampler interface {
 	BatchSize() int
 }
 
+// SeededSampler is a Sampler whose random draws can be made reproducible
+// by seeding. Each call to Seed restarts the random sequence of Sample.
+type SeededSampler interface {
+	Sampler
+	Seed(seed int64)
+}
+
+// samplerRand returns seeded generator r or a new time-seeded one if r is nil.
+func samplerRand(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
 // SequentialSampler represents a method to
 // draw sample by its order in the dataset.
 type SequentialSampler struct {
@@ -45,15 +61,17 @@ func (s *SequentialSampler) BatchSize() int {
 // RandomSampler represents a method to draw
 // a sample randomly from dataset.
 type RandomSampler struct {
-	n           int  // number of samples
-	size        int  // size of sampling
-	replacement bool // whether replacement or not
-	batchSize   int  // always = 1
+	n           int        // number of samples
+	size        int        // size of sampling
+	replacement bool       // whether replacement or not
+	batchSize   int        // always = 1
+	rng         *rand.Rand // nil unless seeded
 }
 
 type RandOptions struct {
 	Size        int
 	Replacement bool
+	Rand        *rand.Rand // random generator. Nil means seeding with current time.
 }
 
 type RandOption func(*RandOptions)
@@ -62,6 +80,7 @@ func NewRandOptions(options ...RandOption) RandOptions {
 	opts := RandOptions{
 		Size:        0,
 		Replacement: false,
+		Rand:        nil,
 	}
 
 	for _, o := range options {
@@ -83,17 +102,38 @@ func WithReplacement(replacement bool) RandOption {
 	}
 }
 
+// WithRandSeed makes sampling reproducible by drawing from a generator seeded with seed.
+func WithRandSeed(seed int64) RandOption {
+	return func(o *RandOptions) {
+		o.Rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithRand sets random generator to draw from. Note that *rand.Rand is not
+// safe for concurrent use.
+func WithRand(r *rand.Rand) RandOption {
+	return func(o *RandOptions) {
+		o.Rand = r
+	}
+}
+
 // NewRandomSampler creates a new RandomSampler.
 //
 // n : number of samples in dataset
-// size: Optional (default=n). Size of sampling.
-// replacement: Optional (default=false). Whether not repeated or repeated samples.
+// size: Optional (default=n). Size of sampling, 0 means n. It can be greater than n only with replacement.
+// replacement: Optional (default=false). Whether samples are drawn with replacement, i.e. can be repeated.
+// rand: Optional (default=nil). Random generator (see WithRandSeed, WithRand). If nil, sampling is seeded with current time.
 func NewRandomSampler(n int, opt ...RandOption) (*RandomSampler, error) {
 
 	opts := NewRandOptions(opt...)
 
-	if opts.Size > n {
-		err := fmt.Errorf("Sampling size can not be greater than number of samples.")
+	if opts.Size < 0 {
+		err := fmt.Errorf("Expected non-negative sampling size. Got: %v\n", opts.Size)
+		return nil, err
+	}
+
+	if opts.Size > n && !opts.Replacement {
+		err := fmt.Errorf("Sampling size can not be greater than number of samples without replacement.")
 		return nil, err
 	}
 
@@ -107,45 +147,29 @@ func NewRandomSampler(n int, opt ...RandOption) (*RandomSampler, error) {
 		size:        size,
 		replacement: opts.Replacement,
 		batchSize:   1,
+		rng:         opts.Rand,
 	}, nil
 }
 
 // Sample implements Sampler interface.
 func (s *RandomSampler) Sample() []int {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	var indices []int
+	r := samplerRand(s.rng)
 
-	if !s.replacement {
-		for i := 0; i < s.size; i++ {
-			idx := r.Intn(s.n)
-			indices = append(indices, idx)
+	if s.replacement {
+		indices := make([]int, s.size)
+		for i := range indices {
+			indices[i] = r.Intn(s.n)
 		}
 		return indices
 	}
 
-	if s.size == s.n {
-		indices = r.Perm(s.n)
-		return indices
-	}
-
-	// Random range with fixed length
-	var max, min int
-	for {
-		max = r.Intn(s.n)
-		min = max - s.size
-		if min >= 0 {
-			break
-		}
-	}
-
-	// Random permutation in a range [min, max)
-	// ref. https://stackoverflow.com/questions/35354800
-	indices = r.Perm(max - min)
-	for i := range indices {
-		indices[i] += min
-	}
+	// Random subset in random order
+	return r.Perm(s.n)[:s.size]
+}
 
-	return indices
+// Seed implements SeededSampler interface.
+func (s *RandomSampler) Seed(seed int64) {
+	s.rng = rand.New(rand.NewSource(seed))
 }
 
 // BatchSize implements Sampler interface.
@@ -168,25 +192,78 @@ type BatchSampler struct {
 	batchSize int
 	shuffle   bool
 	dropLast  bool
+	rng       *rand.Rand // nil unless seeded
+}
+
+type BatchOptions struct {
+	Shuffle bool       // whether shuffling samples
+	Rand    *rand.Rand // random generator for shuffling. Nil means seeding with current time.
+}
+
+type BatchOption func(*BatchOptions)
+
+func NewBatchOptions(options ...BatchOption) BatchOptions {
+	opts := BatchOptions{
+		Shuffle: false,
+		Rand:    nil,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithBatchShuffle(shuffle bool) BatchOption {
+	return func(o *BatchOptions) {
+		o.Shuffle = shuffle
+	}
+}
+
+// WithBatchSeed makes shuffled batches reproducible by drawing from a generator seeded with seed.
+func WithBatchSeed(seed int64) BatchOption {
+	return func(o *BatchOptions) {
+		o.Rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithBatchRand sets random generator used for shuffling. Note that
+// *rand.Rand is not safe for concurrent use.
+func WithBatchRand(r *rand.Rand) BatchOption {
+	return func(o *BatchOptions) {
+		o.Rand = r
+	}
 }
 
 // NewBatchSampler creates a new BatchSampler.
 func NewBatchSampler(n, batchSize int, dropLast bool, shuffleOpt ...bool) (*BatchSampler, error) {
+	shuffle := false
+	if len(shuffleOpt) > 0 {
+		shuffle = shuffleOpt[0]
+	}
+
+	return NewBatchSamplerWithOptions(n, batchSize, dropLast, WithBatchShuffle(shuffle))
+}
+
+// NewBatchSamplerWithOptions creates a new BatchSampler.
+//
+// shuffle: Optional (default=false). Whether samples are shuffled.
+// rand: Optional (default=nil). Random generator (see WithBatchSeed, WithBatchRand). If nil, shuffling is seeded with current time.
+func NewBatchSamplerWithOptions(n, batchSize int, dropLast bool, opt ...BatchOption) (*BatchSampler, error) {
 	if batchSize > n || batchSize <= 1 {
 		err := fmt.Errorf("Invalid batch size: batch size must be greater than 1 and less or equal to number of samples(%v).", n)
 		return nil, err
 	}
 
-	shuffle := false
-	if len(shuffleOpt) > 0 {
-		shuffle = shuffleOpt[0]
-	}
+	opts := NewBatchOptions(opt...)
 
 	return &BatchSampler{
 		n:         n,
 		batchSize: batchSize,
-		shuffle:   shuffle,
+		shuffle:   opts.Shuffle,
 		dropLast:  dropLast,
+		rng:       opts.Rand,
 	}, nil
 }
 
@@ -205,8 +282,7 @@ func (s *BatchSampler) Sample() []int {
 		}
 	case true:
 		// random permutation
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		indices = r.Perm(s.n)
+		indices = samplerRand(s.rng).Perm(s.n)
 	}
 
 	for _, i := range indices {
@@ -223,6 +299,11 @@ func (s *BatchSampler) Sample() []int {
 	return batches
 }
 
+// Seed implements SeededSampler interface.
+func (s *BatchSampler) Seed(seed int64) {
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
 // BatchSize returns batch size.
 func (s *BatchSampler) BatchSize() int {
 	return s.batchSize
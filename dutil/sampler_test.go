@@ -2,10 +2,11 @@ package dutil_test
 
 import (
 	// "fmt"
+	"math/rand"
 	"reflect"
 	"testing"
 
-	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/iseg/dutil"
 )
 
 func TestSequentialSampler(t *testing.T) {
@@ -33,8 +34,8 @@ func TestRandomSampler(t *testing.T) {
 		t.Errorf("Got: %+v\n", got)
 	}
 
-	// Replacement Opt
-	s1, err := dutil.NewRandomSampler(3, dutil.WithReplacement(true))
+	// Without replacement
+	s1, err := dutil.NewRandomSampler(3, dutil.WithReplacement(false))
 	if err != nil {
 		t.Errorf("Unexpected error. Got: %v\n", err)
 	}
@@ -44,6 +45,19 @@ func TestRandomSampler(t *testing.T) {
 		t.Errorf("Unexpected duplicated elements. Got: %+v\n", indices)
 	}
 
+	// With replacement, sampling size can exceed number of samples.
+	s3, err := dutil.NewRandomSampler(3, dutil.WithReplacement(true), dutil.WithSize(10))
+	if err != nil {
+		t.Errorf("Unexpected error. Got: %v\n", err)
+	}
+	indices = s3.Sample()
+	if len(indices) != 10 || !isDup(indices) {
+		t.Errorf("Expected 10 indices with duplicates. Got: %+v\n", indices)
+	}
+	if _, err := dutil.NewRandomSampler(3, dutil.WithSize(10)); err == nil {
+		t.Errorf("Expected sampling size error.")
+	}
+
 	// Size option
 	size := 3
 	s2, err := dutil.NewRandomSampler(10, dutil.WithSize(size))
@@ -56,6 +70,18 @@ func TestRandomSampler(t *testing.T) {
 		t.Errorf("Want size: %v\n", size)
 		t.Errorf("Got size: %v\n", len(indices))
 	}
+
+	// Zero size means all samples.
+	s4, err := dutil.NewRandomSampler(10, dutil.WithSize(0))
+	if err != nil {
+		t.Fatalf("Unexpected error. Got: %v\n", err)
+	}
+	if indices = s4.Sample(); len(indices) != 10 || isDup(indices) {
+		t.Errorf("Expected 10 distinct indices. Got: %+v\n", indices)
+	}
+	if _, err := dutil.NewRandomSampler(10, dutil.WithSize(-1)); err == nil {
+		t.Errorf("Expected negative sampling size error.")
+	}
 }
 
 func isDup(input []int) bool {
@@ -135,6 +161,73 @@ func TestBatchSampler_Sample(t *testing.T) {
 	}
 }
 
+func TestSampler_Seed(t *testing.T) {
+	newRandom := func(opts ...dutil.RandOption) dutil.Sampler {
+		s, err := dutil.NewRandomSampler(100, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	newBatch := func(opts ...dutil.BatchOption) dutil.Sampler {
+		s, err := dutil.NewBatchSamplerWithOptions(100, 8, false, append(opts, dutil.WithBatchShuffle(true))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		s1    dutil.Sampler
+		s2    dutil.Sampler
+		other dutil.Sampler
+	}{
+		{
+			"RandomSampler",
+			newRandom(dutil.WithRandSeed(42)),
+			newRandom(dutil.WithRand(rand.New(rand.NewSource(42)))),
+			newRandom(dutil.WithRandSeed(7)),
+		},
+		{
+			"RandomSampler replacement",
+			newRandom(dutil.WithRandSeed(42), dutil.WithReplacement(true), dutil.WithSize(150)),
+			newRandom(dutil.WithRandSeed(42), dutil.WithReplacement(true), dutil.WithSize(150)),
+			newRandom(dutil.WithRandSeed(7), dutil.WithReplacement(true), dutil.WithSize(150)),
+		},
+		{
+			"BatchSampler",
+			newBatch(dutil.WithBatchSeed(42)),
+			newBatch(dutil.WithBatchRand(rand.New(rand.NewSource(42)))),
+			newBatch(dutil.WithBatchSeed(7)),
+		},
+	}
+
+	for _, tt := range tests {
+		// Same sequence of samples across repeated calls.
+		for i := 0; i < 2; i++ {
+			want, got := tt.s1.Sample(), tt.s2.Sample()
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%v - Want: %v\n", tt.name, want)
+				t.Errorf("%v - Got: %v\n", tt.name, got)
+			}
+			if reflect.DeepEqual(want, tt.other.Sample()) {
+				t.Errorf("%v - Expected different sample with different seed.", tt.name)
+			}
+		}
+	}
+
+	// Seed restarts sequence.
+	s := newBatch().(*dutil.BatchSampler)
+	s.Seed(3)
+	want := s.Sample()
+	s.Seed(3)
+	if got := s.Sample(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
 func seq(n int) []int {
 	var s []int
 	for i := 0; i < n; i++ {
@@ -0,0 +1,74 @@
+package dutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VOCClasses are Pascal VOC segmentation class names. Class index is the
+// position in the list, with background 0. Object boundaries and difficult
+// regions are marked with 255 in masks.
+var VOCClasses = []string{
+	"background", "aeroplane", "bicycle", "bird", "boat",
+	"bottle", "bus", "car", "cat", "chair",
+	"cow", "diningtable", "dog", "horse", "motorbike",
+	"person", "pottedplant", "sheep", "sofa", "train",
+	"tvmonitor",
+}
+
+// readLines reads non-empty, trimmed lines of a text file.
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// NewVOCDataset creates a dataset from Pascal VOC directory layout (e.g. "VOCdevkit/VOC2012"):
+//
+//	root/ImageSets/Segmentation/<split>.txt
+//	root/JPEGImages/<id>.jpg
+//	root/SegmentationClass/<id>.png
+//
+// split is a split file name without extension, e.g. "train", "val" or "trainval".
+// Masks are paletted PNGs whose palette indices are class indices (see VOCClasses)
+// and 255 marks boundaries to be ignored.
+func NewVOCDataset(root, split string, options ...FolderOption) (*SegmentationFolderDataset, error) {
+	splitFile := filepath.Join(root, "ImageSets", "Segmentation", split+".txt")
+	ids, err := readLines(splitFile)
+	if err != nil {
+		err = fmt.Errorf("Reading VOC split %q failed: %v\n", split, err)
+		return nil, err
+	}
+
+	imageFiles := make([]string, len(ids))
+	maskFiles := make([]string, len(ids))
+	for i, id := range ids {
+		imageFiles[i] = filepath.Join(root, "JPEGImages", id+".jpg")
+		maskFiles[i] = filepath.Join(root, "SegmentationClass", id+".png")
+		if _, err := os.Stat(maskFiles[i]); err != nil {
+			err = fmt.Errorf("No mask found for VOC image %q: %v\n", id, err)
+			return nil, err
+		}
+	}
+
+	return NewSegmentationFileDataset(ids, imageFiles, maskFiles, options...)
+}
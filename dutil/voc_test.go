@@ -0,0 +1,88 @@
+package dutil_test
+
+import (
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+func newTestVOC(t *testing.T) (root string) {
+	root, err := ioutil.TempDir("", "voc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"ImageSets/Segmentation", "JPEGImages", "SegmentationClass"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	split := "2007_000032\n2007_000039\n\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "ImageSets/Segmentation/train.txt"), []byte(split), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "ImageSets/Segmentation/val.txt"), []byte("2007_000033\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"2007_000032", "2007_000039"} {
+		f, err := os.Create(filepath.Join(root, "JPEGImages", id+".jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 3, 2)), nil); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		mask := image.NewPaletted(image.Rect(0, 0, 3, 2), dutil.VOCColors(256))
+		mask.Pix = []uint8{0, 15, 255, 0, 1, 1}
+		writePNG(t, filepath.Join(root, "SegmentationClass", id+".png"), mask)
+	}
+
+	return root
+}
+
+func TestVOCDataset(t *testing.T) {
+	root := newTestVOC(t)
+	defer os.RemoveAll(root)
+
+	ds, err := dutil.NewVOCDataset(root, "train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"2007_000032", "2007_000039"}
+	if !reflect.DeepEqual(wantNames, ds.Names()) {
+		t.Errorf("Want: %v\n", wantNames)
+		t.Errorf("Got: %v\n", ds.Names())
+	}
+
+	item, err := ds.Item(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := item.(*dutil.SegmentationSample)
+	want := []int64{0, 15, 255, 0, 1, 1}
+	if !reflect.DeepEqual(want, s.Mask.Int64Values()) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", s.Mask.Int64Values())
+	}
+	if got := s.Image.MustSize(); !reflect.DeepEqual([]int64{3, 2, 3}, got) {
+		t.Errorf("Want: %v\n", []int64{3, 2, 3})
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Split lists an image without mask.
+	if _, err := dutil.NewVOCDataset(root, "val"); err == nil {
+		t.Errorf("Expected missing mask error.")
+	}
+	if _, err := dutil.NewVOCDataset(root, "test"); err == nil {
+		t.Errorf("Expected missing split error.")
+	}
+}
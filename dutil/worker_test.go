@@ -0,0 +1,175 @@
+package dutil_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// slowDataset returns its index as item after a delay that varies by index
+// so that concurrent loads finish out of order.
+type slowDataset struct {
+	n      int
+	failAt int // index failing to load, -1 for none
+	loaded int64
+}
+
+func (ds *slowDataset) Item(idx int) (interface{}, error) {
+	atomic.AddInt64(&ds.loaded, 1)
+	time.Sleep(time.Duration((ds.n-idx)%4) * time.Millisecond)
+	if idx == ds.failAt {
+		return nil, fmt.Errorf("Failed loading item %v\n", idx)
+	}
+
+	return idx, nil
+}
+
+func (ds *slowDataset) Len() int {
+	return ds.n
+}
+
+func (ds *slowDataset) DType() reflect.Type {
+	return reflect.TypeOf([]int{})
+}
+
+func (ds *slowDataset) ItemType() reflect.Type {
+	return ds.DType().Elem()
+}
+
+func (ds *slowDataset) Loaded() int {
+	return int(atomic.LoadInt64(&ds.loaded))
+}
+
+func collectBatches(t *testing.T, dl *dutil.DataLoader) []interface{} {
+	t.Helper()
+	var batches []interface{}
+	for dl.HasNext() {
+		batch, err := dl.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+func TestDataLoader_Workers(t *testing.T) {
+	ds := &slowDataset{n: 20, failAt: -1}
+	s, err := dutil.NewBatchSampler(20, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dl, err := dutil.NewDataLoader(ds, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := collectBatches(t, dl)
+
+	dl, err = dutil.NewDataLoader(ds, s, dutil.WithWorkers(4), dutil.WithPrefetch(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+	got := collectBatches(t, dl)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Iterate again after reset.
+	dl.Reset()
+	got = collectBatches(t, dl)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	if _, err := dutil.NewDataLoader(ds, s, dutil.WithWorkers(-1)); err == nil {
+		t.Errorf("Expected invalid workers error.")
+	}
+	if _, err := dutil.NewDataLoader(ds, s, dutil.WithPrefetch(0)); err == nil {
+		t.Errorf("Expected invalid prefetch error.")
+	}
+}
+
+func TestDataLoader_WorkersError(t *testing.T) {
+	ds := &slowDataset{n: 10, failAt: 4}
+	dl, err := dutil.NewDataLoader(ds, nil, dutil.WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+
+	for i := 0; i < 4; i++ {
+		item, err := dl.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item != i {
+			t.Errorf("Want: %v\n", i)
+			t.Errorf("Got: %v\n", item)
+		}
+	}
+
+	// Failed item is retried by next call.
+	for i := 0; i < 2; i++ {
+		if _, err := dl.Next(); err == nil {
+			t.Errorf("Expected loading error.")
+		}
+	}
+	if !dl.HasNext() {
+		t.Errorf("Expected remaining items.")
+	}
+}
+
+func TestDataLoader_Prefetch(t *testing.T) {
+	ds := &slowDataset{n: 20, failAt: -1}
+	dl, err := dutil.NewDataLoader(ds, nil, dutil.WithWorkers(4), dutil.WithPrefetch(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dl.Next(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// 1 consumed + at most 2 prefetched
+	if loaded := ds.Loaded(); loaded > 3 {
+		t.Errorf("Want at most 3 loaded items. Got: %v\n", loaded)
+	}
+
+	// No loading after close.
+	dl.Close()
+	loaded := ds.Loaded()
+	time.Sleep(20 * time.Millisecond)
+	if ds.Loaded() != loaded {
+		t.Errorf("Want: %v\n", loaded)
+		t.Errorf("Got: %v\n", ds.Loaded())
+	}
+}
+
+func TestDataLoader_Context(t *testing.T) {
+	ds := &slowDataset{n: 20, failAt: -1}
+	ctx, cancel := context.WithCancel(context.Background())
+	dl, err := dutil.NewDataLoader(ds, nil, dutil.WithWorkers(2), dutil.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+
+	if _, err := dl.Next(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := dl.Next(); err != context.Canceled {
+		t.Errorf("Want: %v\n", context.Canceled)
+		t.Errorf("Got: %v\n", err)
+	}
+}
@@ -0,0 +1,217 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// BoundaryLoss calculates boundary loss from probabilities (i.e. after softmax
+// or sigmoid) and precomputed signed distance maps of ground truth (see
+// SignedDistanceMap) of the same shape, e.g. [B, C, H, W].
+//
+// It's usually combined with a regional loss such as DiceLoss or CrossEntropyLoss.
+// Ref. https://arxiv.org/abs/1812.07032
+func BoundaryLoss(prob, sdm *ts.Tensor) *ts.Tensor {
+	d := sdm.MustTotype(prob.DType(), false).MustTo(prob.MustDevice(), true)
+	retVal := prob.MustMul(d, false).MustMean(prob.DType(), true)
+	d.MustDrop()
+
+	return retVal
+}
+
+// HausdorffDTLoss calculates distance transform based Hausdorff loss from
+// probabilities, binary target and precomputed distance maps of target (see
+// DistanceMap), all of the same shape.
+//
+// predDist is an optional distance map of thresholded prediction. It's
+// expensive to compute per step, so if it is nil, only target distances are used.
+// alpha: Optional (default=2.0). Exponent of distances.
+// Ref. https://arxiv.org/abs/1904.10030
+func HausdorffDTLoss(prob, target, targetDist, predDist *ts.Tensor, alphaOpt ...float64) *ts.Tensor {
+	alpha := 2.0
+	if len(alphaOpt) > 0 {
+		alpha = alphaOpt[0]
+	}
+
+	dtype := prob.DType()
+	device := prob.MustDevice()
+
+	t := target.MustTotype(dtype, false).MustTo(device, true)
+	errSq := prob.MustSub(t, false).MustPowTensorScalar(ts.FloatScalar(2.0), true)
+	t.MustDrop()
+
+	dist := targetDist.MustTotype(dtype, false).MustTo(device, true).MustPowTensorScalar(ts.FloatScalar(alpha), true)
+	if predDist != nil {
+		pd := predDist.MustTotype(dtype, false).MustTo(device, true).MustPowTensorScalar(ts.FloatScalar(alpha), true)
+		dist = dist.MustAdd(pd, true)
+		pd.MustDrop()
+	}
+
+	retVal := errSq.MustMul(dist, true).MustMean(dtype, true)
+	dist.MustDrop()
+
+	return retVal
+}
+
+// BoundaryF1Score computes boundary F1 score between binary prediction and
+// target of size h x w. A boundary pixel is matched if it lies within
+// tolerance (in pixels) of the other boundary.
+//
+// It returns 0 if only one of the masks is empty. Empty prediction and target
+// are scored by empty policy (see MetricOptions).
+// Ref. Csurka et al., "What is a good evaluation measure for semantic segmentation?", 2013.
+func BoundaryF1Score(pred, target []bool, h, w int, tolerance float64, opts ...MetricOption) float64 {
+	score, _ := boundaryF1(pred, target, h, w, tolerance, NewMetricOptions(opts...))
+	return score
+}
+
+// boundaryF1 computes boundary F1 score and whether it should be counted in averages.
+func boundaryF1(pred, target []bool, h, w int, tolerance float64, o MetricOptions) (float64, bool) {
+	sp := Surface(pred, h, w)
+	st := Surface(target, h, w)
+
+	dp := EuclideanDistance(sp, h, w) // distance to predicted boundary
+	dt := EuclideanDistance(st, h, w) // distance to target boundary
+
+	var np, nt, matchedP, matchedT float64
+	for i := range sp {
+		if sp[i] {
+			np++
+			if dt[i] <= tolerance {
+				matchedP++
+			}
+		}
+		if st[i] {
+			nt++
+			if dp[i] <= tolerance {
+				matchedT++
+			}
+		}
+	}
+
+	switch {
+	case np == 0 && nt == 0:
+		return o.empty(1)
+	case np == 0 || nt == 0:
+		return 0, true
+	}
+
+	precision := matchedP / np
+	recall := matchedT / nt
+	if precision+recall == 0 {
+		return 0, true
+	}
+
+	return 2 * precision * recall / (precision + recall), true
+}
+
+// innerBand returns foreground pixels within distance d of the object contour.
+// Pixels outside the image are treated as background.
+func innerBand(fg []bool, h, w int, d float64) []bool {
+	// Pad with 1 pixel of background.
+	ph, pw := h+2, w+2
+	bg := make([]bool, ph*pw)
+	for i := range bg {
+		bg[i] = true
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			bg[(y+1)*pw+x+1] = !fg[y*w+x]
+		}
+	}
+
+	dist := EuclideanDistance(bg, ph, pw) // distance to background
+	band := make([]bool, h*w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			band[i] = fg[i] && dist[(y+1)*pw+x+1] <= d
+		}
+	}
+
+	return band
+}
+
+// BoundaryIoUScore computes boundary IoU between binary prediction and target
+// of size h x w: IoU of mask pixels within distance dilation (in pixels) of
+// their contours.
+//
+// It returns 0 if only one of the masks is empty. Empty prediction and target
+// are scored by empty policy (see MetricOptions).
+// Ref. https://arxiv.org/abs/2103.16562
+func BoundaryIoUScore(pred, target []bool, h, w int, dilation float64, opts ...MetricOption) float64 {
+	score, _ := boundaryIoU(pred, target, h, w, dilation, NewMetricOptions(opts...))
+	return score
+}
+
+// boundaryIoU computes boundary IoU and whether it should be counted in averages.
+func boundaryIoU(pred, target []bool, h, w int, dilation float64, o MetricOptions) (float64, bool) {
+	bp := innerBand(pred, h, w, dilation)
+	bt := innerBand(target, h, w, dilation)
+
+	var intersection, union float64
+	for i := range bp {
+		if bp[i] && bt[i] {
+			intersection++
+		}
+		if bp[i] || bt[i] {
+			union++
+		}
+	}
+
+	if union == 0 {
+		return o.empty(1)
+	}
+
+	return intersection / union, true
+}
+
+// boundaryScoreBatch averages fn over every h x w plane of binary pred and
+// target. Planes of empty prediction and target are handled by empty policy.
+func boundaryScoreBatch(fn func(pred, target []bool, h, w int, d float64, o MetricOptions) (float64, bool), pred, target *ts.Tensor, d float64, opts []MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	pvals, h, w := planeValues(pred)
+	tvals, _, _ := planeValues(target)
+
+	var (
+		scores []float64
+		oks    []bool
+	)
+	p := make([]bool, h*w)
+	t := make([]bool, h*w)
+	for offset := 0; offset < len(pvals); offset += h * w {
+		for i := range p {
+			p[i] = pvals[offset+i] != 0
+			t[i] = tvals[offset+i] != 0
+		}
+		score, ok := fn(p, t, h, w, d, o)
+		scores = append(scores, score)
+		oks = append(oks, ok)
+	}
+
+	return meanScores(scores, oks)
+}
+
+// BoundaryF1 calculates boundary F1 score (see BoundaryF1Score) averaged over
+// a batch of binary prediction and target of shape [B, H, W]. Nonzero values are foreground.
+//
+// tolerance: matching distance in pixels, e.g. 2.0.
+func BoundaryF1(pred, target *ts.Tensor, tolerance float64, opts ...MetricOption) float64 {
+	return boundaryScoreBatch(boundaryF1, pred, target, tolerance, opts)
+}
+
+// BoundaryDilation returns default band width of boundary IoU of images of
+// size h x w: 2% of image diagonal, at least 1 pixel.
+func BoundaryDilation(h, w int) float64 {
+	diag := math.Sqrt(float64(h*h + w*w))
+	return math.Max(1, math.Round(0.02*diag))
+}
+
+// BoundaryIoU calculates boundary IoU (see BoundaryIoUScore) averaged over
+// a batch of binary prediction and target of shape [B, H, W]. Nonzero values are foreground.
+//
+// dilation: band width in pixels, e.g. BoundaryDilation(h, w).
+func BoundaryIoU(pred, target *ts.Tensor, dilation float64, opts ...MetricOption) float64 {
+	return boundaryScoreBatch(boundaryIoU, pred, target, dilation, opts)
+}
@@ -0,0 +1,142 @@
+package metric_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestBoundaryF1Score(t *testing.T) {
+	top := []bool{
+		true, true, true,
+		false, false, false,
+		false, false, false,
+	}
+	bottom := []bool{
+		false, false, false,
+		false, false, false,
+		true, true, true,
+	}
+	upper := []bool{
+		true, true, true,
+		true, true, true,
+		false, false, false,
+	}
+	lower := []bool{
+		false, false, false,
+		true, true, true,
+		true, true, true,
+	}
+	empty := make([]bool, 9)
+
+	tests := []struct {
+		name      string
+		pred      []bool
+		target    []bool
+		tolerance float64
+		want      float64
+	}{
+		{"identical", top, top, 0, 1},
+		{"2 pixels apart, within tolerance", top, bottom, 2, 1},
+		{"2 pixels apart, out of tolerance", top, bottom, 1, 0},
+		{"half matched", upper, lower, 0, 0.5},
+		{"both empty", empty, empty, 0, 1},
+		{"empty prediction", empty, top, 2, 0},
+	}
+
+	for _, tt := range tests {
+		got := metric.BoundaryF1Score(tt.pred, tt.target, 3, 3, tt.tolerance)
+		if math.Abs(tt.want-got) > 1e-9 {
+			t.Errorf("%v - Want: %v\n", tt.name, tt.want)
+			t.Errorf("%v - Got: %v\n", tt.name, got)
+		}
+	}
+}
+
+func TestBoundaryIoUScore(t *testing.T) {
+	upper := []bool{
+		true, true, true,
+		true, true, true,
+		false, false, false,
+	}
+	lower := []bool{
+		false, false, false,
+		true, true, true,
+		true, true, true,
+	}
+
+	got := metric.BoundaryIoUScore(upper, lower, 3, 3, 1)
+	want := 1.0 / 3
+	if math.Abs(want-got) > 1e-9 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Interior pixels of a large object are excluded from the band.
+	h, w := 5, 5
+	square := make([]bool, h*w)
+	for i := range square {
+		square[i] = true
+	}
+	shrunk := make([]bool, h*w)
+	copy(shrunk, square)
+	shrunk[12] = false // center pixel
+
+	got = metric.BoundaryIoUScore(square, shrunk, h, w, 1)
+	// square band: 16 border pixels. shrunk band: 16 border + 4 neighbours of center.
+	want = 16.0 / 20
+	if math.Abs(want-got) > 1e-9 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestBoundaryF1(t *testing.T) {
+	pred := ts.MustOfSlice([]int64{
+		1, 1, 0, 0,
+		0, 0, 0, 0,
+	}).MustView([]int64{2, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{
+		1, 1, 0, 0,
+		0, 0, 1, 0,
+	}).MustView([]int64{2, 2, 2}, true)
+
+	// sample 1: identical (1.0), sample 2: empty prediction (0.0)
+	got := metric.BoundaryF1(pred, target, 2)
+	if math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("Want: %v\n", 0.5)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestBoundaryF1_EmptyPolicy(t *testing.T) {
+	// sample 1: empty prediction (0.0), sample 2: both empty
+	pred := ts.MustOfSlice([]int64{
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+	}).MustView([]int64{2, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{
+		1, 1, 0, 0,
+		0, 0, 0, 0,
+	}).MustView([]int64{2, 2, 2}, true)
+
+	tests := []struct {
+		name   string
+		policy metric.EmptyPolicy
+		want   float64
+	}{
+		{"score one", metric.EmptyScoreOne, 0.5},
+		{"skip", metric.EmptySkip, 0},
+		{"nan", metric.EmptyNaN, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		got := metric.BoundaryF1(pred, target, 2, metric.WithEmptyPolicy(tt.policy))
+		assertFloats(t, "BoundaryF1 "+tt.name, []float64{tt.want}, []float64{got})
+		got = metric.BoundaryIoU(pred, target, 1, metric.WithEmptyPolicy(tt.policy))
+		assertFloats(t, "BoundaryIoU "+tt.name, []float64{tt.want}, []float64{got})
+	}
+}
@@ -0,0 +1,283 @@
+package metric
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// Calibration accumulates confidence and accuracy of pixel predictions in
+// equal-width confidence bins over batches to measure how trustworthy
+// predicted probabilities are.
+type Calibration struct {
+	nbins       int64
+	ignoreIndex int64
+	hasIgnore   bool
+	count       []float64 // number of pixels per bin
+	confSum     []float64 // sum of confidences per bin
+	correctSum  []float64 // number of correctly predicted pixels per bin
+}
+
+// NewCalibration creates a new Calibration.
+//
+// nbins: number of confidence bins (e.g. 15).
+// ignoreIndex: Optional. Target value to exclude from counting.
+func NewCalibration(nbins int64, ignoreIndexOpt ...int64) *Calibration {
+	c := &Calibration{
+		nbins:      nbins,
+		count:      make([]float64, nbins),
+		confSum:    make([]float64, nbins),
+		correctSum: make([]float64, nbins),
+	}
+	if len(ignoreIndexOpt) > 0 {
+		c.ignoreIndex = ignoreIndexOpt[0]
+		c.hasIgnore = true
+	}
+
+	return c
+}
+
+// Update adds a batch of probabilities and target.
+//
+// For multiclass, prob is softmax output of shape [B, C, H, W] and target holds
+// class indices of shape [B, H, W]. For binary, prob is sigmoid output of the
+// same shape as binary target, and confidence of a pixel is max(p, 1-p).
+func (c *Calibration) Update(prob, target *ts.Tensor) {
+	var conf, correct *ts.Tensor
+	if prob.Dim() == target.Dim()+1 {
+		var pred *ts.Tensor
+		conf, pred = prob.MustMaxDim(1, false, false)
+		t := target.MustTotype(gotch.Int64, false)
+		correct = pred.MustEqTensor(t, true)
+		t.MustDrop()
+	} else {
+		p := prob.MustTotype(gotch.Double, false)
+		predPos := p.MustGe(ts.FloatScalar(0.5), false)
+		oneMinus := p.MustMulScalar(ts.FloatScalar(-1.0), false).MustAddScalar(ts.FloatScalar(1.0), true)
+		conf = p.MustWhereSelf(predPos, oneMinus, true)
+		oneMinus.MustDrop()
+		targetPos := target.MustGt(ts.FloatScalar(0.5), false)
+		correct = predPos.MustEqTensor(targetPos, true)
+		targetPos.MustDrop()
+	}
+	conf = conf.MustTotype(gotch.Double, true).MustReshape([]int64{-1}, true)
+	correct = correct.MustTotype(gotch.Double, true).MustReshape([]int64{-1}, true)
+
+	if c.hasIgnore {
+		valid := target.MustNe(ts.IntScalar(c.ignoreIndex), false).MustReshape([]int64{-1}, true)
+		conf = conf.MustMaskedSelect(valid, true)
+		correct = correct.MustMaskedSelect(valid, true)
+		valid.MustDrop()
+	}
+
+	idx := conf.MustMulScalar(ts.FloatScalar(float64(c.nbins)), false).MustFloor(true)
+	idx = idx.MustClamp(ts.FloatScalar(0), ts.FloatScalar(float64(c.nbins-1)), true).MustTotype(gotch.Int64, true)
+
+	counts := idx.MustBincount(ts.NewTensor(), c.nbins, false).MustTotype(gotch.Double, true).MustTo(gotch.CPU, true)
+	confSum := idx.MustBincount(conf, c.nbins, false).MustTo(gotch.CPU, true)
+	correctSum := idx.MustBincount(correct, c.nbins, true).MustTo(gotch.CPU, true)
+	conf.MustDrop()
+	correct.MustDrop()
+
+	countVals := counts.Float64Values()
+	confVals := confSum.Float64Values()
+	correctVals := correctSum.Float64Values()
+	for i := range c.count {
+		c.count[i] += countVals[i]
+		c.confSum[i] += confVals[i]
+		c.correctSum[i] += correctVals[i]
+	}
+	counts.MustDrop()
+	confSum.MustDrop()
+	correctSum.MustDrop()
+}
+
+// Reset clears all accumulated counts.
+func (c *Calibration) Reset() {
+	for i := range c.count {
+		c.count[i] = 0
+		c.confSum[i] = 0
+		c.correctSum[i] = 0
+	}
+}
+
+// CalibrationBin holds statistics of a confidence bin.
+type CalibrationBin struct {
+	Lower      float64 // lower bound of confidence
+	Upper      float64 // upper bound of confidence
+	Confidence float64 // mean confidence. NaN if bin is empty.
+	Accuracy   float64 // ratio of correct predictions. NaN if bin is empty.
+	Count      int64
+}
+
+// Bins returns statistics of all confidence bins.
+func (c *Calibration) Bins() []CalibrationBin {
+	bins := make([]CalibrationBin, c.nbins)
+	for i := range bins {
+		bins[i] = CalibrationBin{
+			Lower:      float64(i) / float64(c.nbins),
+			Upper:      float64(i+1) / float64(c.nbins),
+			Confidence: ratio(c.confSum[i], c.count[i]),
+			Accuracy:   ratio(c.correctSum[i], c.count[i]),
+			Count:      int64(c.count[i]),
+		}
+	}
+
+	return bins
+}
+
+// ECE returns expected calibration error: mean absolute difference between
+// accuracy and confidence of bins, weighted by bin sizes.
+// Ref. https://arxiv.org/abs/1706.04599
+func (c *Calibration) ECE() float64 {
+	var ece, total float64
+	for i, n := range c.count {
+		total += n
+		if n == 0 {
+			continue
+		}
+		ece += math.Abs(c.correctSum[i] - c.confSum[i]) // = n * |acc - conf|
+	}
+
+	return ratio(ece, total)
+}
+
+// MCE returns maximum calibration error: maximum absolute difference between
+// accuracy and confidence over non-empty bins.
+func (c *Calibration) MCE() float64 {
+	mce := math.NaN()
+	for _, b := range c.Bins() {
+		if b.Count == 0 {
+			continue
+		}
+		gap := math.Abs(b.Accuracy - b.Confidence)
+		if math.IsNaN(mce) || gap > mce {
+			mce = gap
+		}
+	}
+
+	return mce
+}
+
+// PlotReliability saves a reliability diagram (accuracy per confidence bin
+// against perfect calibration diagonal) to file.
+// Image format is inferred from file extension (e.g. .png, .svg, .pdf).
+func (c *Calibration) PlotReliability(file string) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = "Reliability diagram"
+	p.X.Label.Text = "Confidence"
+	p.Y.Label.Text = "Accuracy"
+	p.X.Min, p.X.Max = 0, 1
+	p.Y.Min, p.Y.Max = 0, 1
+
+	hist := &plotter.Histogram{
+		Width:     1.0 / float64(c.nbins),
+		FillColor: color.RGBA{R: 70, G: 130, B: 180, A: 255},
+		LineStyle: plotter.DefaultLineStyle,
+	}
+	for _, b := range c.Bins() {
+		acc := b.Accuracy
+		if math.IsNaN(acc) {
+			acc = 0
+		}
+		hist.Bins = append(hist.Bins, plotter.HistogramBin{Min: b.Lower, Max: b.Upper, Weight: acc})
+	}
+
+	diagonal := plotter.NewFunction(func(x float64) float64 { return x })
+	diagonal.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+
+	p.Add(hist, diagonal)
+	p.Legend.Add("Accuracy", hist)
+	p.Legend.Add("Perfect calibration", diagonal)
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	return p.Save(5*vg.Inch, 5*vg.Inch, file)
+}
+
+// TemperatureScaler calibrates logits by dividing them by a single scalar
+// temperature fitted on validation data.
+// Ref. https://arxiv.org/abs/1706.04599
+type TemperatureScaler struct {
+	Temperature float64
+}
+
+// NewTemperatureScaler creates a TemperatureScaler with temperature 1.0 (no scaling).
+func NewTemperatureScaler() *TemperatureScaler {
+	return &TemperatureScaler{Temperature: 1.0}
+}
+
+// temperatureNLL returns negative log-likelihood of logits scaled by temperature.
+func temperatureNLL(logits, target *ts.Tensor, temperature float64, ignoreIndex []int64) float64 {
+	var nll float64
+	ts.NoGrad(func() {
+		scaled := logits.MustDivScalar(ts.FloatScalar(temperature), false)
+		var loss *ts.Tensor
+		if logits.Dim() == target.Dim()+1 {
+			var opts []CrossEntropyOption
+			if len(ignoreIndex) > 0 {
+				opts = append(opts, WithIgnoreIndex(ignoreIndex[0]))
+			}
+			loss = CrossEntropyLoss(scaled, target, opts...)
+		} else {
+			t := target.MustTotype(scaled.DType(), false)
+			loss = scaled.MustBinaryCrossEntropyWithLogits(t, ts.NewTensor(), ts.NewTensor(), int64(ReductionMean), false)
+			t.MustDrop()
+		}
+		scaled.MustDrop()
+		nll = loss.Float64Values()[0]
+		loss.MustDrop()
+	})
+
+	return nll
+}
+
+// Fit finds temperature minimizing negative log-likelihood of validation
+// logits and target and returns the minimal NLL.
+//
+// For multiclass, logits are of shape [B, C, H, W] and target holds class indices
+// of shape [B, H, W]. For binary, logits and binary target have the same shape.
+// ignoreIndex: Optional. Target value to exclude (multiclass only).
+func (s *TemperatureScaler) Fit(logits, target *ts.Tensor, ignoreIndexOpt ...int64) float64 {
+	// Golden-section search of log temperature in [log(0.05), log(20)].
+	// NOTE. NLL is unimodal in temperature.
+	f := func(logT float64) float64 {
+		return temperatureNLL(logits, target, math.Exp(logT), ignoreIndexOpt)
+	}
+
+	gr := (math.Sqrt(5) - 1) / 2
+	a, b := math.Log(0.05), math.Log(20.0)
+	x1 := b - gr*(b-a)
+	x2 := a + gr*(b-a)
+	f1, f2 := f(x1), f(x2)
+	for b-a > 1e-4 {
+		if f1 < f2 {
+			b, x2, f2 = x2, x1, f1
+			x1 = b - gr*(b-a)
+			f1 = f(x1)
+		} else {
+			a, x1, f1 = x1, x2, f2
+			x2 = a + gr*(b-a)
+			f2 = f(x2)
+		}
+	}
+
+	logT := (a + b) / 2
+	s.Temperature = math.Exp(logT)
+
+	return f(logT)
+}
+
+// Apply scales logits by fitted temperature. Probabilities are then obtained
+// with softmax (multiclass) or sigmoid (binary) as usual.
+func (s *TemperatureScaler) Apply(logits *ts.Tensor) *ts.Tensor {
+	return logits.MustDivScalar(ts.FloatScalar(s.Temperature), false)
+}
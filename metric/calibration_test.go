@@ -0,0 +1,96 @@
+package metric_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestCalibration_Multiclass(t *testing.T) {
+	c := metric.NewCalibration(10)
+	// pixel 0: confidence 0.9, correct. pixel 1: confidence 0.7, wrong.
+	prob := ts.MustOfSlice([]float64{0.9, 0.3, 0.1, 0.7}).MustView([]int64{1, 2, 1, 2}, true)
+	target := ts.MustOfSlice([]int64{0, 0}).MustView([]int64{1, 1, 2}, true)
+	c.Update(prob, target)
+
+	bins := c.Bins()
+	assertFloats(t, "Accuracy", []float64{0, 1}, []float64{bins[7].Accuracy, bins[9].Accuracy})
+	assertFloats(t, "Confidence", []float64{0.7, 0.9}, []float64{bins[7].Confidence, bins[9].Confidence})
+	assertFloats(t, "ECE", []float64{0.4}, []float64{c.ECE()})
+	assertFloats(t, "MCE", []float64{0.7}, []float64{c.MCE()})
+}
+
+func TestCalibration_Binary(t *testing.T) {
+	c := metric.NewCalibration(10)
+	prob := ts.MustOfSlice([]float64{0.8, 0.2, 0.6, 0.4}).MustView([]int64{1, 2, 2}, true)
+	target := ts.MustOfSlice([]float64{1, 0, 0, 0}).MustView([]int64{1, 2, 2}, true)
+	c.Update(prob, target)
+
+	bins := c.Bins()
+	if bins[6].Count != 2 || bins[8].Count != 2 {
+		t.Errorf("Want: %v\n", []int64{2, 2})
+		t.Errorf("Got: %v\n", []int64{bins[6].Count, bins[8].Count})
+	}
+	assertFloats(t, "Accuracy", []float64{0.5, 1}, []float64{bins[6].Accuracy, bins[8].Accuracy})
+	assertFloats(t, "ECE", []float64{0.15}, []float64{c.ECE()})
+
+	c.Reset()
+	if !math.IsNaN(c.ECE()) {
+		t.Errorf("Want NaN ECE after reset.")
+	}
+}
+
+func TestCalibration_PlotReliability(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calibration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := metric.NewCalibration(10)
+	prob := ts.MustOfSlice([]float64{0.8, 0.2, 0.6, 0.4}).MustView([]int64{1, 2, 2}, true)
+	target := ts.MustOfSlice([]float64{1, 0, 0, 0}).MustView([]int64{1, 2, 2}, true)
+	c.Update(prob, target)
+
+	file := filepath.Join(dir, "reliability.png")
+	if err := c.PlotReliability(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("Want plot file: %v\n", file)
+	}
+}
+
+func TestTemperatureScaler(t *testing.T) {
+	// Every pixel has logit 2 and 3 of 4 pixels are positive, so optimal
+	// probability is 0.75, i.e. 2/T = ln(3).
+	want := 2 / math.Log(3)
+
+	logits := ts.MustOfSlice([]float64{2, 2, 2, 2}).MustView([]int64{1, 2, 2}, true)
+	target := ts.MustOfSlice([]float64{1, 1, 1, 0}).MustView([]int64{1, 2, 2}, true)
+	s := metric.NewTemperatureScaler()
+	s.Fit(logits, target)
+	if math.Abs(s.Temperature-want) > 1e-3 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", s.Temperature)
+	}
+
+	// Same problem as 2-class logits.
+	logits = ts.MustOfSlice([]float64{2, 2, 2, 2, 0, 0, 0, 0}).MustView([]int64{1, 2, 2, 2}, true)
+	target = ts.MustOfSlice([]int64{0, 0, 0, 1}).MustView([]int64{1, 2, 2}, true)
+	s = metric.NewTemperatureScaler()
+	s.Fit(logits, target)
+	if math.Abs(s.Temperature-want) > 1e-3 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", s.Temperature)
+	}
+
+	scaled := s.Apply(logits)
+	assertFloats(t, "Apply", []float64{2 / s.Temperature}, scaled.Float64Values()[:1])
+}
@@ -0,0 +1,267 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// ConfusionMatrix accumulates a confusion matrix over batches, i.e. a whole
+// validation epoch, so that metrics are computed from total counts rather
+// than averaged from per-batch scores.
+//
+// Rows are ground truth classes and columns are predicted classes.
+type ConfusionMatrix struct {
+	nclasses    int64
+	ignoreIndex int64
+	hasIgnore   bool
+	counts      []int64 // nclasses x nclasses, row-major
+}
+
+// NewConfusionMatrix creates a new ConfusionMatrix.
+//
+// nclasses: number of classes.
+// ignoreIndex: Optional. Target value to exclude from counting (e.g. 255).
+func NewConfusionMatrix(nclasses int64, ignoreIndexOpt ...int64) *ConfusionMatrix {
+	cm := &ConfusionMatrix{
+		nclasses: nclasses,
+		counts:   make([]int64, nclasses*nclasses),
+	}
+	if len(ignoreIndexOpt) > 0 {
+		cm.ignoreIndex = ignoreIndexOpt[0]
+		cm.hasIgnore = true
+	}
+
+	return cm
+}
+
+// Update adds a batch of prediction and target to the confusion matrix.
+//
+// pred can be either class indices of the same shape as target (e.g. [B, H, W])
+// or logits/probabilities with an extra class dimension at axis 1 (e.g. [B, C, H, W]).
+// Target pixels out of range [0, nclasses) or equal to ignore index are skipped.
+func (cm *ConfusionMatrix) Update(pred, target *ts.Tensor) {
+	var p *ts.Tensor
+	if pred.Dim() == target.Dim()+1 {
+		p = pred.MustArgmax([]int64{1}, false, false)
+	} else {
+		p = pred.MustTotype(gotch.Int64, false)
+	}
+	p = p.MustReshape([]int64{-1}, true)
+	t := target.MustTotype(gotch.Int64, false).MustReshape([]int64{-1}, true)
+
+	n := cm.nclasses
+	t1 := t.MustGe(ts.IntScalar(0), false)
+	t2 := t.MustLt(ts.IntScalar(n), false)
+	valid := t1.MustLogicalAnd(t2, true)
+	t2.MustDrop()
+	if cm.hasIgnore {
+		t3 := t.MustNe(ts.IntScalar(cm.ignoreIndex), false)
+		valid = valid.MustLogicalAnd(t3, true)
+		t3.MustDrop()
+	}
+	p1 := p.MustGe(ts.IntScalar(0), false)
+	p2 := p.MustLt(ts.IntScalar(n), false)
+	valid = valid.MustLogicalAnd(p1, true).MustLogicalAnd(p2, true)
+	p1.MustDrop()
+	p2.MustDrop()
+
+	tIdx := t.MustMaskedSelect(valid, true).MustMulScalar(ts.IntScalar(n), true)
+	pIdx := p.MustMaskedSelect(valid, true)
+	valid.MustDrop()
+
+	hist := tIdx.MustAdd(pIdx, true).MustBincount(ts.NewTensor(), n*n, true).MustTo(gotch.CPU, true)
+	pIdx.MustDrop()
+
+	for i, v := range hist.Int64Values() {
+		cm.counts[i] += v
+	}
+	hist.MustDrop()
+}
+
+// Reset clears all accumulated counts.
+func (cm *ConfusionMatrix) Reset() {
+	for i := range cm.counts {
+		cm.counts[i] = 0
+	}
+}
+
+// NClasses returns number of classes.
+func (cm *ConfusionMatrix) NClasses() int64 {
+	return cm.nclasses
+}
+
+// Matrix returns a copy of accumulated counts as [target][pred].
+func (cm *ConfusionMatrix) Matrix() [][]int64 {
+	n := int(cm.nclasses)
+	mat := make([][]int64, n)
+	for i := 0; i < n; i++ {
+		mat[i] = make([]int64, n)
+		copy(mat[i], cm.counts[i*n:(i+1)*n])
+	}
+
+	return mat
+}
+
+// stats returns per-class true positives, false positives and false negatives.
+func (cm *ConfusionMatrix) stats() (tp, fp, fn []float64) {
+	n := int(cm.nclasses)
+	tp = make([]float64, n)
+	fp = make([]float64, n)
+	fn = make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			v := float64(cm.counts[i*n+j])
+			if i == j {
+				tp[i] += v
+				continue
+			}
+			fn[i] += v // target i predicted as j
+			fp[j] += v // predicted j but target i
+		}
+	}
+
+	return tp, fp, fn
+}
+
+// ratio returns a/b or NaN if b is zero.
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return math.NaN()
+	}
+	return a / b
+}
+
+// nanMean returns mean of values ignoring NaN. It returns NaN if all values are NaN.
+func nanMean(values []float64) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sum += v
+		n++
+	}
+
+	return ratio(sum, float64(n))
+}
+
+// overlapStats returns per-class numerators k*TP and denominators
+// k*TP + FP + FN, i.e. IoU for k = 1 and Dice for k = 2.
+func (cm *ConfusionMatrix) overlapStats(k float64) (nums, dens []float64) {
+	tp, fp, fn := cm.stats()
+	nums = make([]float64, len(tp))
+	dens = make([]float64, len(tp))
+	for i := range tp {
+		nums[i] = k * tp[i]
+		dens[i] = k*tp[i] + fp[i] + fn[i]
+	}
+
+	return nums, dens
+}
+
+// IoU returns per-class intersection over union: TP / (TP + FP + FN).
+// Classes absent from both prediction and target are scored by empty policy
+// (default=EmptySkip, i.e. NaN). See MetricOptions.
+func (cm *ConfusionMatrix) IoU(opts ...MetricOption) []float64 {
+	o := newMetricOptions(EmptySkip, opts)
+	return o.scores(cm.overlapStats(1))
+}
+
+// Dice returns per-class Dice coefficient (F1 score): 2TP / (2TP + FP + FN).
+// Classes absent from both prediction and target are scored by empty policy
+// (default=EmptySkip, i.e. NaN). See MetricOptions.
+func (cm *ConfusionMatrix) Dice(opts ...MetricOption) []float64 {
+	o := newMetricOptions(EmptySkip, opts)
+	return o.scores(cm.overlapStats(2))
+}
+
+// Precision returns per-class precision: TP / (TP + FP).
+// Classes never predicted get NaN.
+func (cm *ConfusionMatrix) Precision() []float64 {
+	tp, fp, _ := cm.stats()
+	precision := make([]float64, len(tp))
+	for i := range tp {
+		precision[i] = ratio(tp[i], tp[i]+fp[i])
+	}
+
+	return precision
+}
+
+// Recall returns per-class recall (per-class accuracy): TP / (TP + FN).
+// Classes absent from target get NaN.
+func (cm *ConfusionMatrix) Recall() []float64 {
+	tp, _, fn := cm.stats()
+	recall := make([]float64, len(tp))
+	for i := range tp {
+		recall[i] = ratio(tp[i], tp[i]+fn[i])
+	}
+
+	return recall
+}
+
+// Support returns number of target pixels per class.
+func (cm *ConfusionMatrix) Support() []int64 {
+	n := int(cm.nclasses)
+	support := make([]int64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			support[i] += cm.counts[i*n+j]
+		}
+	}
+
+	return support
+}
+
+// PixelAccuracy returns ratio of correctly classified pixels over all counted pixels.
+func (cm *ConfusionMatrix) PixelAccuracy() float64 {
+	tp, _, _ := cm.stats()
+	var correct, total float64
+	for _, v := range tp {
+		correct += v
+	}
+	for _, v := range cm.counts {
+		total += float64(v)
+	}
+
+	return ratio(correct, total)
+}
+
+// MeanAccuracy returns mean of per-class recall over classes present in target.
+func (cm *ConfusionMatrix) MeanAccuracy() float64 {
+	return nanMean(cm.Recall())
+}
+
+// MeanIoU returns mean of per-class IoU. By default, classes absent from
+// both prediction and target are skipped (see MetricOptions).
+func (cm *ConfusionMatrix) MeanIoU(opts ...MetricOption) float64 {
+	o := newMetricOptions(EmptySkip, opts)
+	return o.mean(cm.overlapStats(1))
+}
+
+// MeanDice returns mean of per-class Dice. By default, classes absent from
+// both prediction and target are skipped (see MetricOptions).
+func (cm *ConfusionMatrix) MeanDice(opts ...MetricOption) float64 {
+	o := newMetricOptions(EmptySkip, opts)
+	return o.mean(cm.overlapStats(2))
+}
+
+// FrequencyWeightedIoU returns per-class IoU weighted by class frequency in target.
+func (cm *ConfusionMatrix) FrequencyWeightedIoU() float64 {
+	iou := cm.IoU()
+	support := cm.Support()
+	var sum, total float64
+	for i, s := range support {
+		total += float64(s)
+		if s == 0 || math.IsNaN(iou[i]) {
+			continue
+		}
+		sum += float64(s) * iou[i]
+	}
+
+	return ratio(sum, total)
+}
@@ -0,0 +1,102 @@
+package metric_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestConfusionMatrix(t *testing.T) {
+	cm := metric.NewConfusionMatrix(3, 255)
+
+	// Batch 1
+	pred1 := ts.MustOfSlice([]int64{0, 0, 1, 1}).MustView([]int64{1, 2, 2}, true)
+	target1 := ts.MustOfSlice([]int64{0, 1, 1, 255}).MustView([]int64{1, 2, 2}, true)
+	cm.Update(pred1, target1)
+
+	// Batch 2: logits of shape [B, C, H, W]
+	logits2 := ts.MustOfSlice([]float64{
+		// class 0
+		1, 0,
+		// class 1
+		0, 0,
+		// class 2
+		0, 1,
+	}).MustView([]int64{1, 3, 1, 2}, true)
+	target2 := ts.MustOfSlice([]int64{0, 2}).MustView([]int64{1, 1, 2}, true)
+	cm.Update(logits2, target2)
+
+	wantMat := [][]int64{
+		{2, 0, 0},
+		{1, 1, 0},
+		{0, 0, 1},
+	}
+	if !reflect.DeepEqual(wantMat, cm.Matrix()) {
+		t.Errorf("Want: %v\n", wantMat)
+		t.Errorf("Got: %v\n", cm.Matrix())
+	}
+
+	assertFloats(t, "IoU", []float64{2.0 / 3, 0.5, 1}, cm.IoU())
+	assertFloats(t, "Dice", []float64{0.8, 2.0 / 3, 1}, cm.Dice())
+	assertFloats(t, "Precision", []float64{2.0 / 3, 1, 1}, cm.Precision())
+	assertFloats(t, "Recall", []float64{1, 0.5, 1}, cm.Recall())
+	assertFloats(t, "PixelAccuracy", []float64{0.8}, []float64{cm.PixelAccuracy()})
+	assertFloats(t, "MeanAccuracy", []float64{2.5 / 3}, []float64{cm.MeanAccuracy()})
+	assertFloats(t, "MeanIoU", []float64{(2.0/3 + 0.5 + 1) / 3}, []float64{cm.MeanIoU()})
+	assertFloats(t, "FrequencyWeightedIoU", []float64{(2*2.0/3 + 2*0.5 + 1) / 5}, []float64{cm.FrequencyWeightedIoU()})
+
+	cm.Reset()
+	if !math.IsNaN(cm.MeanIoU()) {
+		t.Errorf("Want NaN mean IoU after reset. Got: %v\n", cm.MeanIoU())
+	}
+}
+
+func TestConfusionMatrix_EmptyPolicy(t *testing.T) {
+	cm := metric.NewConfusionMatrix(3)
+	pred := ts.MustOfSlice([]int64{0, 0, 1, 1}).MustView([]int64{1, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{0, 1, 1, 1}).MustView([]int64{1, 2, 2}, true)
+	cm.Update(pred, target)
+
+	// class 2 is absent from both prediction and target.
+	tests := []struct {
+		name     string
+		opts     []metric.MetricOption
+		wantIoU  []float64
+		wantMean float64
+	}{
+		{"default", nil, []float64{0.5, 2.0 / 3, math.NaN()}, (0.5 + 2.0/3) / 2},
+		{"score one", []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyScoreOne)}, []float64{0.5, 2.0 / 3, 1}, (0.5 + 2.0/3 + 1) / 3},
+		{"nan", []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyNaN)}, []float64{0.5, 2.0 / 3, math.NaN()}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		assertFloats(t, tt.name+" IoU", tt.wantIoU, cm.IoU(tt.opts...))
+		assertFloats(t, tt.name+" MeanIoU", []float64{tt.wantMean}, []float64{cm.MeanIoU(tt.opts...)})
+	}
+
+	r, err := metric.NewReportWithOptions(cm, nil, metric.WithEmptyPolicy(metric.EmptyScoreOne))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloats(t, "Report IoU", []float64{1, (0.5 + 2.0/3 + 1) / 3}, []float64{r.Classes[2].IoU, r.Macro.IoU})
+}
+
+func assertFloats(t *testing.T, name string, want, got []float64) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Errorf("%v - Want: %v\n", name, want)
+		t.Errorf("%v - Got: %v\n", name, got)
+		return
+	}
+	for i := range want {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("%v - Want: %v\n", name, want)
+			t.Errorf("%v - Got: %v\n", name, got)
+			return
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package metric
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Reduction specifies how a per-element loss is reduced to output.
+//
+// NOTE. Values follow libtorch convention: none = 0; mean = 1; sum = 2.
+type Reduction int64
+
+const (
+	ReductionNone Reduction = iota
+	ReductionMean
+	ReductionSum
+)
+
+// CrossEntropyOptions holds optional settings for CrossEntropyLoss.
+type CrossEntropyOptions struct {
+	Weights        []float64 // per-class weights. Nil means equal weights.
+	IgnoreIndex    int64     // target value that does not contribute to the loss.
+	LabelSmoothing float64   // amount of smoothing in range [0.0, 1.0]
+	Reduction      Reduction
+}
+
+type CrossEntropyOption func(*CrossEntropyOptions)
+
+func NewCrossEntropyOptions(options ...CrossEntropyOption) CrossEntropyOptions {
+	opts := CrossEntropyOptions{
+		Weights:        nil,
+		IgnoreIndex:    -100, // Pytorch default
+		LabelSmoothing: 0.0,
+		Reduction:      ReductionMean,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithClassWeights(weights []float64) CrossEntropyOption {
+	return func(o *CrossEntropyOptions) {
+		o.Weights = weights
+	}
+}
+
+func WithIgnoreIndex(ignoreIndex int64) CrossEntropyOption {
+	return func(o *CrossEntropyOptions) {
+		o.IgnoreIndex = ignoreIndex
+	}
+}
+
+func WithLabelSmoothing(smoothing float64) CrossEntropyOption {
+	return func(o *CrossEntropyOptions) {
+		o.LabelSmoothing = smoothing
+	}
+}
+
+func WithReduction(reduction Reduction) CrossEntropyOption {
+	return func(o *CrossEntropyOptions) {
+		o.Reduction = reduction
+	}
+}
+
+// CrossEntropyLoss calculates pixel-wise multiclass cross entropy loss from
+// input logits of shape [B, C, H, W] and mask of class indices of shape [B, H, W].
+//
+// With ReductionNone, it returns loss of shape [B, H, W]. Otherwise, a scalar tensor.
+// Ref. https://pytorch.org/docs/master/generated/torch.nn.CrossEntropyLoss.html
+func CrossEntropyLoss(logits, mask *ts.Tensor, opts ...CrossEntropyOption) *ts.Tensor {
+	o := NewCrossEntropyOptions(opts...)
+
+	// NOTE. an undefined tensor means no weighting.
+	weight := ts.NewTensor()
+	if len(o.Weights) > 0 {
+		weight.MustDrop()
+		weight = ts.MustOfSlice(o.Weights).MustTotype(logits.DType(), true).MustTo(logits.MustDevice(), true)
+	}
+
+	target := mask.MustTotype(gotch.Int64, false)
+	loss := logits.MustCrossEntropyLoss(target, weight, int64(o.Reduction), o.IgnoreIndex, o.LabelSmoothing, false)
+	target.MustDrop()
+	weight.MustDrop()
+
+	return loss
+}
+
+// ClassFrequency counts number of pixels per class in a mask of class indices.
+// Pixels with value out of range [0, nclasses) or equal to optional ignore index
+// are not counted.
+//
+// Counts from multiple masks (i.e. a whole dataset) can be summed up and passed
+// to ClassWeights.
+func ClassFrequency(mask *ts.Tensor, nclasses int64, ignoreIndexOpt ...int64) []int64 {
+	m := mask.MustTotype(gotch.Int64, false).MustView([]int64{-1}, true)
+
+	t1 := m.MustGe(ts.IntScalar(0), false)
+	t2 := m.MustLt(ts.IntScalar(nclasses), false)
+	valid := t1.MustLogicalAnd(t2, true)
+	t2.MustDrop()
+	if len(ignoreIndexOpt) > 0 {
+		t3 := m.MustNe(ts.IntScalar(ignoreIndexOpt[0]), false)
+		valid = valid.MustLogicalAnd(t3, true)
+		t3.MustDrop()
+	}
+
+	x := m.MustMaskedSelect(valid, true)
+	valid.MustDrop()
+
+	counts := x.MustBincount(ts.NewTensor(), nclasses, true)
+	retVal := counts.Int64Values()
+	counts.MustDrop()
+
+	return retVal
+}
+
+// WeightMethod specifies how class weights are derived from class frequencies.
+type WeightMethod int
+
+const (
+	// InverseFrequency weighs class c by N / (C * n_c) where C is number of
+	// classes present.
+	InverseFrequency WeightMethod = iota
+	// InverseSqrtFrequency weighs class c by 1/sqrt(f_c), normalized to mean 1.
+	InverseSqrtFrequency
+	// MedianFrequency weighs class c by median(f) / f_c.
+	// Ref. https://arxiv.org/abs/1411.4734
+	MedianFrequency
+	// LogFrequency weighs class c by 1 / ln(1.02 + f_c).
+	// Ref. https://arxiv.org/abs/1606.02147 (ENet)
+	LogFrequency
+)
+
+// ClassWeights computes per-class loss weights from pixel counts per class
+// (see ClassFrequency). Classes that never appear get zero weight.
+func ClassWeights(counts []int64, method WeightMethod) []float64 {
+	var total int64
+	var nonzero []float64
+	for _, c := range counts {
+		total += c
+		if c > 0 {
+			nonzero = append(nonzero, float64(c))
+		}
+	}
+
+	weights := make([]float64, len(counts))
+	if total == 0 {
+		return weights
+	}
+
+	var median float64
+	if method == MedianFrequency {
+		sort.Float64s(nonzero)
+		n := len(nonzero)
+		median = nonzero[n/2]
+		if n%2 == 0 {
+			median = (nonzero[n/2-1] + nonzero[n/2]) / 2
+		}
+	}
+
+	var sum float64
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		f := float64(c) / float64(total)
+		switch method {
+		case InverseFrequency:
+			weights[i] = 1.0 / (float64(len(nonzero)) * f)
+		case InverseSqrtFrequency:
+			weights[i] = 1.0 / math.Sqrt(f)
+		case MedianFrequency:
+			weights[i] = median / float64(c)
+		case LogFrequency:
+			weights[i] = 1.0 / math.Log(1.02+f)
+		}
+		sum += weights[i]
+	}
+
+	if method == InverseSqrtFrequency {
+		mean := sum / float64(len(nonzero))
+		for i := range weights {
+			weights[i] /= mean
+		}
+	}
+
+	return weights
+}
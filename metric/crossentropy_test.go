@@ -0,0 +1,133 @@
+package metric_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestCrossEntropyLoss(t *testing.T) {
+	// Uniform logits: loss = ln(nclasses) for every pixel.
+	nclasses := int64(4)
+	logits := ts.MustZeros([]int64{2, nclasses, 3, 3}, gotch.Float, gotch.CPU)
+	mask := ts.MustZeros([]int64{2, 3, 3}, gotch.Int64, gotch.CPU)
+
+	loss := metric.CrossEntropyLoss(logits, mask)
+	got := loss.Float64Values()[0]
+	want := math.Log(float64(nclasses))
+	if math.Abs(want-got) > 1e-5 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Per-pixel loss
+	loss = metric.CrossEntropyLoss(logits, mask, metric.WithReduction(metric.ReductionNone))
+	if !reflect.DeepEqual([]int64{2, 3, 3}, loss.MustSize()) {
+		t.Errorf("Want loss shape: %v\n", []int64{2, 3, 3})
+		t.Errorf("Got loss shape: %v\n", loss.MustSize())
+	}
+}
+
+func TestCrossEntropyLoss_IgnoreIndex(t *testing.T) {
+	logits := ts.MustZeros([]int64{1, 2, 2, 2}, gotch.Float, gotch.CPU)
+	mask := ts.MustOfSlice([]int64{255, 255, 255, 255}).MustView([]int64{1, 2, 2}, true)
+
+	loss := metric.CrossEntropyLoss(logits, mask, metric.WithIgnoreIndex(255), metric.WithReduction(metric.ReductionSum))
+	got := loss.Float64Values()[0]
+	if got != 0 {
+		t.Errorf("Want: 0\n")
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestCrossEntropyLoss_Weights(t *testing.T) {
+	// Pixel 0: p = [1/4, 3/4], target 0; pixel 1: p = [1/2, 1/2], target 1;
+	// pixel 2: ignored.
+	ln3 := float32(math.Log(3))
+	logits := ts.MustOfSlice([]float32{0, 0, 0, ln3, 0, 0}).MustView([]int64{1, 2, 1, 3}, true)
+	mask := ts.MustOfSlice([]int64{0, 1, 255}).MustView([]int64{1, 1, 3}, true)
+
+	// Weighted mean: (2*ln4 + 1*ln2) / (2 + 1)
+	loss := metric.CrossEntropyLoss(logits, mask, metric.WithClassWeights([]float64{2, 1}), metric.WithIgnoreIndex(255))
+	got := loss.Float64Values()[0]
+	want := 5 * math.Ln2 / 3
+	if math.Abs(want-got) > 1e-5 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestCrossEntropyLoss_LabelSmoothing(t *testing.T) {
+	// p = [1/4, 3/4], target 0. Smoothed target is [1 - 0.1 + 0.1/2, 0.1/2].
+	logits := ts.MustOfSlice([]float32{0, float32(math.Log(3))}).MustView([]int64{1, 2, 1, 1}, true)
+	mask := ts.MustZeros([]int64{1, 1, 1}, gotch.Int64, gotch.CPU)
+
+	loss := metric.CrossEntropyLoss(logits, mask, metric.WithLabelSmoothing(0.1))
+	got := loss.Float64Values()[0]
+	want := 0.95*math.Log(4) + 0.05*math.Log(4.0/3)
+	if math.Abs(want-got) > 1e-5 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestClassFrequency(t *testing.T) {
+	mask := ts.MustOfSlice([]int64{0, 0, 1, 2, 255, 2, 2, 0, 1}).MustView([]int64{1, 3, 3}, true)
+
+	want := []int64{3, 2, 3}
+	got := metric.ClassFrequency(mask, 3, 255)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestClassWeights(t *testing.T) {
+	counts := []int64{10, 30, 0, 60}
+
+	got := metric.ClassWeights(counts, metric.InverseFrequency)
+	want := []float64{100.0 / 30, 100.0 / 90, 0, 100.0 / 180}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	got = metric.ClassWeights(counts, metric.MedianFrequency)
+	want = []float64{3, 1, 0, 0.5}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	// Frequencies: 0.1, 0.3, 0.6
+	invSqrt := []float64{1 / math.Sqrt(0.1), 1 / math.Sqrt(0.3), 0, 1 / math.Sqrt(0.6)}
+	mean := (invSqrt[0] + invSqrt[1] + invSqrt[3]) / 3
+	tests := []struct {
+		method metric.WeightMethod
+		want   []float64
+	}{
+		{metric.InverseSqrtFrequency, []float64{invSqrt[0] / mean, invSqrt[1] / mean, 0, invSqrt[3] / mean}},
+		{metric.LogFrequency, []float64{1 / math.Log(1.12), 1 / math.Log(1.32), 0, 1 / math.Log(1.62)}},
+	}
+	for _, tt := range tests {
+		got = metric.ClassWeights(counts, tt.method)
+		for i := range tt.want {
+			if math.Abs(tt.want[i]-got[i]) > 1e-9 {
+				t.Errorf("Want: %v\n", tt.want)
+				t.Errorf("Got: %v\n", got)
+				break
+			}
+		}
+	}
+}
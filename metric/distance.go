@@ -0,0 +1,238 @@
+package metric
+
+import (
+	"log"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// NOTE. a large finite value is used instead of +Inf so that
+// parabola intersections in dt1d stay well-defined.
+const edtInf float64 = 1e20
+
+// EuclideanDistance computes exact Euclidean distance from every pixel of a
+// binary image of size h x w (row-major) to its nearest foreground pixel.
+// Foreground pixels get distance 0. If there is no foreground pixel at all,
+// every distance is +Inf.
+//
+// spacing: Optional (default=1.0). Pixel spacing along rows and columns.
+// If only one value is given, it is used for both.
+// Ref. Felzenszwalb & Huttenlocher, "Distance Transforms of Sampled Functions", 2012.
+func EuclideanDistance(fg []bool, h, w int, spacing ...float64) []float64 {
+	sy, sx := pixelSpacing(spacing)
+	f := make([]float64, h*w)
+	for i, v := range fg {
+		if !v {
+			f[i] = edtInf
+		}
+	}
+
+	n := h
+	if w > n {
+		n = w
+	}
+	buf := make([]float64, n)
+	out := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+
+	// Transform along columns then along rows.
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			buf[y] = f[y*w+x]
+		}
+		dt1d(buf[:h], out[:h], v, z, sy)
+		for y := 0; y < h; y++ {
+			f[y*w+x] = out[y]
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		copy(buf[:w], f[y*w:(y+1)*w])
+		dt1d(buf[:w], out[:w], v, z, sx)
+		copy(f[y*w:(y+1)*w], out[:w])
+	}
+
+	for i := range f {
+		if f[i] >= edtInf/2 {
+			f[i] = math.Inf(1)
+			continue
+		}
+		f[i] = math.Sqrt(f[i])
+	}
+
+	return f
+}
+
+// pixelSpacing returns row and column spacing from optional spacing values.
+func pixelSpacing(spacing []float64) (sy, sx float64) {
+	switch len(spacing) {
+	case 0:
+		return 1.0, 1.0
+	case 1:
+		return spacing[0], spacing[0]
+	default:
+		return spacing[0], spacing[1]
+	}
+}
+
+// dt1d computes 1D squared distance transform of sampled function f into d
+// using lower envelope of parabolas. Samples are sp apart. v and z are work buffers.
+func dt1d(f, d []float64, v []int, z []float64, sp float64) {
+	n := len(f)
+	if n == 0 {
+		return
+	}
+
+	// position of i-th sample
+	pos := func(i int) float64 {
+		return sp * float64(i)
+	}
+	intersect := func(q, p int) float64 {
+		return ((f[q] + pos(q)*pos(q)) - (f[p] + pos(p)*pos(p))) / (2 * (pos(q) - pos(p)))
+	}
+
+	k := 0
+	v[0] = 0
+	z[0] = math.Inf(-1)
+	z[1] = math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := intersect(q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersect(q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < pos(q) {
+			k++
+		}
+		dq := pos(q) - pos(v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+}
+
+// hasBoundary returns whether a binary image has both foreground and background.
+func hasBoundary(fg []bool) bool {
+	var nfg int
+	for _, v := range fg {
+		if v {
+			nfg++
+		}
+	}
+
+	return nfg > 0 && nfg < len(fg)
+}
+
+// SignedDistance computes signed distance from every pixel of a binary image
+// of size h x w to the object boundary: positive outside, zero on the inner
+// boundary and negative inside. It returns all zeros if the image is
+// entirely foreground or background.
+// Ref. https://github.com/LIVIAETS/boundary-loss
+func SignedDistance(fg []bool, h, w int) []float64 {
+	res := make([]float64, h*w)
+	if !hasBoundary(fg) {
+		return res
+	}
+
+	bg := make([]bool, len(fg))
+	for i, v := range fg {
+		bg[i] = !v
+	}
+
+	dOut := EuclideanDistance(fg, h, w) // distance to foreground. Zero inside.
+	dIn := EuclideanDistance(bg, h, w)  // distance to background. Zero outside.
+	for i := range res {
+		if fg[i] {
+			res[i] = 1 - dIn[i]
+		} else {
+			res[i] = dOut[i]
+		}
+	}
+
+	return res
+}
+
+// BoundaryDistance computes unsigned distance from every pixel of a binary
+// image of size h x w to the nearest pixel of the opposite class. It returns
+// all zeros if the image is entirely foreground or background.
+func BoundaryDistance(fg []bool, h, w int) []float64 {
+	res := make([]float64, h*w)
+	if !hasBoundary(fg) {
+		return res
+	}
+
+	bg := make([]bool, len(fg))
+	for i, v := range fg {
+		bg[i] = !v
+	}
+
+	dOut := EuclideanDistance(fg, h, w)
+	dIn := EuclideanDistance(bg, h, w)
+	for i := range res {
+		res[i] = dOut[i] + dIn[i]
+	}
+
+	return res
+}
+
+// SignedDistanceMap computes signed distance maps (see SignedDistance) of a
+// binary mask tensor on CPU. Nonzero values are foreground. Mask can have any
+// number of leading dimensions, e.g. [H, W], [C, H, W] (one-hot) or [B, C, H, W].
+// It returns a float tensor on CPU of the same shape.
+//
+// NOTE. It's meant to be computed once per sample in dataset pipeline and fed
+// to BoundaryLoss along with the mask.
+func SignedDistanceMap(mask *ts.Tensor) *ts.Tensor {
+	return distanceMap(mask, SignedDistance)
+}
+
+// DistanceMap computes unsigned distance maps (see BoundaryDistance) of a
+// binary mask tensor on CPU. See SignedDistanceMap for supported shapes.
+func DistanceMap(mask *ts.Tensor) *ts.Tensor {
+	return distanceMap(mask, BoundaryDistance)
+}
+
+func distanceMap(mask *ts.Tensor, fn func(fg []bool, h, w int) []float64) *ts.Tensor {
+	size := mask.MustSize()
+	vals, h, w := planeValues(mask)
+
+	out := make([]float32, len(vals))
+	fg := make([]bool, h*w)
+	for offset := 0; offset < len(vals); offset += h * w {
+		for i := range fg {
+			fg[i] = vals[offset+i] != 0
+		}
+		d := fn(fg, h, w)
+		for i, v := range d {
+			out[offset+i] = float32(v)
+		}
+	}
+
+	return ts.MustOfSlice(out).MustView(size, true)
+}
+
+// planeValues copies values of a tensor with at least 2 dimensions to CPU.
+// Values are laid out as consecutive h x w planes.
+func planeValues(x *ts.Tensor) (vals []float64, h, w int) {
+	size := x.MustSize()
+	ndims := len(size)
+	if ndims < 2 {
+		log.Fatalf("Expected tensor with at least 2 dimensions. Got: %v\n", size)
+	}
+	h, w = int(size[ndims-2]), int(size[ndims-1])
+
+	m := x.MustTotype(gotch.Double, false).MustTo(gotch.CPU, true)
+	vals = m.Float64Values()
+	m.MustDrop()
+
+	return vals, h, w
+}
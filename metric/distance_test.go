@@ -0,0 +1,150 @@
+package metric_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestEuclideanDistance(t *testing.T) {
+	// 3x4 image with single foreground pixel at (1, 1)
+	fg := []bool{
+		false, false, false, false,
+		false, true, false, false,
+		false, false, false, false,
+	}
+
+	got := metric.EuclideanDistance(fg, 3, 4)
+	want := []float64{
+		math.Sqrt2, 1, math.Sqrt2, math.Sqrt(5),
+		1, 0, 1, 2,
+		math.Sqrt2, 1, math.Sqrt2, math.Sqrt(5),
+	}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	// Anisotropic spacing
+	got = metric.EuclideanDistance(fg, 3, 4, 2.0, 1.0)
+	want = []float64{
+		math.Sqrt(5), 2, math.Sqrt(5), math.Sqrt(8),
+		1, 0, 1, 2,
+		math.Sqrt(5), 2, math.Sqrt(5), math.Sqrt(8),
+	}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	// No foreground
+	got = metric.EuclideanDistance(make([]bool, 4), 2, 2)
+	for _, d := range got {
+		if !math.IsInf(d, 1) {
+			t.Errorf("Want all +Inf. Got: %v\n", got)
+			break
+		}
+	}
+}
+
+func TestSignedDistance(t *testing.T) {
+	fg := []bool{
+		false, false, false, false, false,
+		false, true, true, true, false,
+		false, true, true, true, false,
+		false, true, true, true, false,
+		false, false, false, false, false,
+	}
+
+	got := metric.SignedDistance(fg, 5, 5)
+	want := []float64{
+		math.Sqrt2, 1, 1, 1, math.Sqrt2,
+		1, 0, 0, 0, 1,
+		1, 0, -1, 0, 1,
+		1, 0, 0, 0, 1,
+		math.Sqrt2, 1, 1, 1, math.Sqrt2,
+	}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-9 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+
+	// No boundary
+	got = metric.SignedDistance(make([]bool, 4), 2, 2)
+	if !reflect.DeepEqual(make([]float64, 4), got) {
+		t.Errorf("Want all zeros. Got: %v\n", got)
+	}
+}
+
+func TestSignedDistanceMap(t *testing.T) {
+	mask := ts.MustOfSlice([]int64{0, 1, 0, 0, 0, 0, 1, 1}).MustView([]int64{2, 2, 2}, true)
+	sdm := metric.SignedDistanceMap(mask)
+
+	if !reflect.DeepEqual([]int64{2, 2, 2}, sdm.MustSize()) {
+		t.Errorf("Want shape: %v\n", []int64{2, 2, 2})
+		t.Errorf("Got shape: %v\n", sdm.MustSize())
+	}
+
+	want := []float64{1, 0, math.Sqrt2, 1, 1, 1, 0, 0}
+	got := sdm.Float64Values()
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-6 {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			break
+		}
+	}
+}
+
+func TestBoundaryLoss(t *testing.T) {
+	prob := ts.MustOfSlice([]float64{1, 0, 0, 0}).MustView([]int64{1, 1, 2, 2}, true)
+	sdm := ts.MustOfSlice([]float64{2, 1, 1, 0}).MustView([]int64{1, 1, 2, 2}, true)
+
+	loss := metric.BoundaryLoss(prob, sdm)
+	got := loss.Float64Values()[0]
+	if math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("Want: %v\n", 0.5)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestHausdorffDTLoss(t *testing.T) {
+	prob := ts.MustOfSlice([]float64{0.5, 0.5, 0, 1}).MustView([]int64{1, 1, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{1, 0, 0, 0}).MustView([]int64{1, 1, 2, 2}, true)
+	targetDist := ts.MustOfSlice([]float64{0, 1, 1, 2}).MustView([]int64{1, 1, 2, 2}, true)
+	predDist := ts.MustOfSlice([]float64{1, 0, 1, 1}).MustView([]int64{1, 1, 2, 2}, true)
+
+	// squared errors: [0.25, 0.25, 0, 1]
+	tests := []struct {
+		name     string
+		predDist *ts.Tensor
+		alpha    []float64
+		want     float64
+	}{
+		{"target distances", nil, nil, (0.25*1 + 1*4) / 4},
+		{"target and prediction distances", predDist, nil, (0.25*1 + 0.25*1 + 1*5) / 4},
+		{"alpha 1", nil, []float64{1}, (0.25*1 + 1*2) / 4},
+	}
+
+	for _, tt := range tests {
+		loss := metric.HausdorffDTLoss(prob, target, targetDist, tt.predDist, tt.alpha...)
+		got := loss.Float64Values()[0]
+		if math.Abs(tt.want-got) > 1e-9 {
+			t.Errorf("%v - Want: %v\n", tt.name, tt.want)
+			t.Errorf("%v - Got: %v\n", tt.name, got)
+		}
+	}
+}
@@ -1,106 +1,111 @@
 package metric
 
 import (
-	"fmt"
-
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/ts"
 )
 
-// DiceLoss calculates intersection over union.
-// Ref: https://www.kaggle.com/vishnukv64/unet-pytorch/comments
-func DiceLoss(pred, target *ts.Tensor) float64 {
-	fmt.Printf("%i", pred)
-	fmt.Printf("%i", target)
-	smooth := 1.0
-	p := pred.MustContiguous(false)
-	t := target.MustContiguous(false)
+// diceStats returns per-sample Dice numerators 2|P∩T| and denominators |P|+|T|.
+func diceStats(pred, target *ts.Tensor) (nums, dens []float64) {
+	p := pred.MustTotype(gotch.Double, false)
+	t := target.MustTotype(gotch.Double, false)
+	pt := p.MustMul(t, false)
 
-	ptMul := p.MustMul(t, false)
-	intersection := ptMul.MustSumDimIntlist([]int64{2}, true, gotch.Double, true).MustSumDimIntlist([]int64{2}, true, gotch.Double, true)
+	inter := sampleSums(pt)
+	pSum := sampleSums(p)
+	tSum := sampleSums(t)
+	pt.MustDrop()
+	p.MustDrop()
+	t.MustDrop()
 
-	pSum := p.MustSumDimIntlist([]int64{2}, true, gotch.Double, true).MustSumDimIntlist([]int64{2}, true, gotch.Double, true)
-	tSum := t.MustSumDimIntlist([]int64{2}, true, gotch.Double, true).MustSumDimIntlist([]int64{2}, true, gotch.Double, true)
-	union := pSum.MustAdd(tSum, true)
-	tSum.MustDrop()
+	nums = make([]float64, len(inter))
+	dens = make([]float64, len(inter))
+	for i := range inter {
+		nums[i] = 2 * inter[i]
+		dens[i] = pSum[i] + tSum[i]
+	}
 
-	numerator := intersection.MustMulScalar(ts.FloatScalar(2.0), true).MustAddScalar(ts.FloatScalar(smooth), true)
-	denominator := union.MustAddScalar(ts.FloatScalar(smooth), true)
+	return nums, dens
+}
 
-	// 1 - (2*intersection + smooth)/(union + smooth)
-	iou := numerator.MustDiv(denominator, true).MustMulScalar(ts.FloatScalar(-1), true).MustAddScalar(ts.FloatScalar(1), true)
-	denominator.MustDrop()
+// planes reshapes x of shape [..., H, W] to [N, H*W], i.e. a row per H x W plane.
+func planes(x *ts.Tensor) *ts.Tensor {
+	size := x.MustSize()
+	n := len(size)
 
-	retVal := iou.MustMean(gotch.Double, true).Float64Values()[0]
+	return x.MustReshape([]int64{-1, size[n-2] * size[n-1]}, false)
+}
 
-	return retVal
+// DiceLoss calculates 1 - Dice coefficient averaged over every H x W plane
+// of prediction and target of the same shape, i.e. over samples and channels
+// of [B, C, H, W].
+//
+// Empty planes and smoothing are handled as specified by options (see MetricOptions).
+// Ref: https://www.kaggle.com/vishnukv64/unet-pytorch/comments
+func DiceLoss(pred, target *ts.Tensor, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	p := planes(pred)
+	t := planes(target)
+	nums, dens := diceStats(p, t)
+	p.MustDrop()
+	t.MustDrop()
+
+	return 1 - o.mean(nums, dens)
 }
 
-// DiceCoeff calculates Intersection over Union.
+// DiceCoeff calculates Dice coefficient of prediction and mask taken as a single sample.
+//
+// Empty masks and smoothing are handled as specified by options (see MetricOptions).
 // Ref. https://github.com/milesial/Pytorch-UNet/blob/master/dice_loss.py
 // NOTE: Dice Coefficient for individual examples.
-func DiceCoeff(prob, mask *ts.Tensor) float64 {
-	eps := 0.0001
-	p := prob.MustView([]int64{-1}, false)
-	m := mask.MustView([]int64{-1}, false)
-
-	// 2 * intersection + eps
-	numerator := p.MustDot(m, false).MustMul1(ts.FloatScalar(2.0), true).MustAdd1(ts.FloatScalar(eps), true)
+func DiceCoeff(prob, mask *ts.Tensor, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	p := prob.MustView([]int64{1, -1}, false)
+	m := mask.MustView([]int64{1, -1}, false)
+	nums, dens := diceStats(p, m)
 	p.MustDrop()
 	m.MustDrop()
 
-	// union
-	pSum := prob.MustSum(gotch.Double, false)
-	mSum := mask.MustSum(gotch.Double, false)
-	denominator := pSum.MustAdd(mSum, true).MustAdd1(ts.FloatScalar(eps), true)
-	mSum.MustDrop()
-
-	dice := numerator.MustDiv(denominator, true)
-	denominator.MustDrop()
-
-	retVal := dice.Float64Values()[0]
-	dice.MustDrop()
-
-	return retVal
+	return o.mean(nums, dens)
 }
 
 // DiceCoeffBatch calculates dice coefficient by batch
+//
+// threshold: Optional (default=0.5). Probability threshold to binarize prediction.
+// See DiceCoeffBatchWithOptions for smoothing and empty sample handling.
 func DiceCoeffBatch(prob, target *ts.Tensor, thresholdOpt ...float64) float64 {
 	var threshold float64 = 0.5
 	if len(thresholdOpt) > 0 {
 		threshold = thresholdOpt[0]
 	}
 
-	pred := prob.MustGreater(ts.FloatScalar(threshold), false).MustTotype(gotch.Float, true)
-	var diceCum float64 = 0
-	bs := int(pred.MustSize()[0])
-	for i := 0; i < bs; i++ {
-		x := pred.Idx(ts.NewSelect(int64(i))) // select first element of axis 0
-		y := target.Idx(ts.NewSelect(int64(i)))
-		dice := DiceCoeff(x, y)
-		diceCum += dice
-		x.MustDrop()
-		y.MustDrop()
-	}
+	return DiceCoeffBatchWithOptions(prob, target, WithThreshold(threshold))
+}
 
+// DiceCoeffBatchWithOptions calculates dice coefficient averaged over samples
+// of a batch. Probabilities are binarized with threshold (see WithThreshold).
+//
+// Empty samples and smoothing are handled as specified by options (see MetricOptions).
+func DiceCoeffBatchWithOptions(prob, target *ts.Tensor, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	pred := prob.MustGreater(ts.FloatScalar(o.Threshold), false)
+	nums, dens := diceStats(pred, target)
 	pred.MustDrop()
 
-	return diceCum / float64(bs)
+	return o.mean(nums, dens)
 }
 
-// FastHist computes confusion mastrix.
+// FastHist computes confusion mastrix of a single batch.
+// See ConfusionMatrix to accumulate over batches.
 // ref: https://github.com/kevinzakka/pytorch-goodies/blob/c039691f349be9f21527bb38b907a940bfc5e8f3/metrics.py#L12
 func FastHist(pred, target *ts.Tensor, nclasses int64) *ts.Tensor {
 	t1 := target.MustGreaterEqual(ts.FloatScalar(0.0), false)
 	t2 := target.MustLess(ts.IntScalar(nclasses), false)
-	fmt.Printf("t1: \n%v", t1)
-	fmt.Printf("t2: \n%v", t2)
 	mask := t1.MustLogicalAnd(t2, false)
 	t2.MustDrop()
 
-	idxTs := []ts.Tensor{*mask}
-	targetIdx := target.MustIndex(idxTs, false).MustMul1(ts.FloatScalar(float64(nclasses)), true)
-	predIdx := pred.MustIndex(idxTs, false)
+	targetIdx := target.MustMaskedSelect(mask, false).MustMulScalar(ts.FloatScalar(float64(nclasses)), true)
+	predIdx := pred.MustMaskedSelect(mask, false)
 	x := targetIdx.MustAdd(predIdx, true).MustTotype(gotch.Int, true)
 	mask.MustDrop()
 	predIdx.MustDrop()
@@ -110,58 +115,51 @@ func FastHist(pred, target *ts.Tensor, nclasses int64) *ts.Tensor {
 	return hist
 }
 
-// JaccardIndex compute the intersection over union.
+// JaccardIndex compute the intersection over union averaged over classes.
+//
+// Classes absent from both prediction and target are handled as specified by
+// empty policy and smoothing is applied per class (see MetricOptions).
 // ref: https://github.com/kevinzakka/pytorch-goodies/blob/c039691f349be9f21527bb38b907a940bfc5e8f3/metrics.py#L12
-func JaccardIndex(pred, target *ts.Tensor, nclasses int64) float64 {
-
+func JaccardIndex(pred, target *ts.Tensor, nclasses int64, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
 	hist := FastHist(pred, target, nclasses)
-	intersect := hist.MustDiag(0, false)
-	eps := 1e-10
-	a := hist.MustSum1([]int64{1}, false, gotch.Double, false)
-	b := hist.MustSum1([]int64{0}, false, gotch.Double, false)
-
-	denominator := a.MustAdd(b, true).MustSub(intersect, true).MustAdd1(ts.FloatScalar(eps), true)
-	b.MustDrop()
-
-	jaccard := intersect.MustDiv(denominator, true)
-
-	denominator.MustDrop()
-
-	mean := jaccard.MustMean(gotch.Double, true)
-	retVal := mean.Float64Values()[0]
-	jaccard.MustDrop()
-	mean.MustDrop()
+	counts := hist.Float64Values()
+	hist.MustDrop()
+
+	n := int(nclasses)
+	intersect := make([]float64, n)
+	union := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			v := counts[i*n+j]
+			union[i] += v
+			if i == j {
+				intersect[i] += v
+				continue
+			}
+			union[j] += v
+		}
+	}
 
-	return retVal
+	return o.mean(intersect, union)
 }
 
-// IoU calculates intersection over union.
+// IoU calculates intersection over union of binary prediction and mask
+// averaged over samples of a batch.
+//
+// Empty samples and smoothing are handled as specified by options (see MetricOptions).
 // Ref: https://discuss.pytorch.org/t/understanding-different-metrics-implementations-iou/85817
-func IoU(logits, mask *ts.Tensor) float64 {
-	eps := 1e-6
+func IoU(logits, mask *ts.Tensor, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
 	pred := logits.MustSqueezeDim(1, false)
-	intersection := pred.MustLogicalAnd(mask, false).MustSumDimIntlist([]int64{1, 2}, false, gotch.Double, true) // Will be zero if pred = 0 or mask = 0
-	union := pred.MustLogicalOr(mask, true).MustSumDimIntlist([]int64{1, 2}, false, gotch.Double, true)          // will be zero if both pred = 0 and mask=0
-
-	numerator := intersection.MustAddScalar(ts.FloatScalar(eps), true)
-	denominator := union.MustAddScalar(ts.FloatScalar(eps), true)
-
-	iou := numerator.MustDiv(denominator, true)
-	denominator.MustDrop()
-	/*
-	 *   //torch.clamp(20 * (iou - 0.5), 0, 10).ceil() / 10
-	 *   x := iou.MustSub1(ts.FloatScalar(0.5), true).MustMul1(ts.IntScalar(20), true)
-	 *   xclamp := x.MustClamp(ts.IntScalar(0), ts.IntScalar(10), true).MustCeil(true)
-	 *   out := xclamp.MustDiv1(ts.IntScalar(10), true)
-	 *   retVal := out.Float64Values()[0]
-	 *   out.MustDrop()
-	 *  */
-
-	iouMean := iou.MustMean(gotch.Double, true)
-	retVal := iouMean.Float64Values()[0]
-	iouMean.MustDrop()
-
-	return retVal
+	and := pred.MustLogicalAnd(mask, false)
+	or := pred.MustLogicalOr(mask, true)
+	intersection := sampleSums(and) // Will be zero if pred = 0 or mask = 0
+	union := sampleSums(or)         // will be zero if both pred = 0 and mask=0
+	and.MustDrop()
+	or.MustDrop()
+
+	return o.mean(intersection, union)
 }
 
 // BCEWithLogitsLoss calculates loss from input logits and mask
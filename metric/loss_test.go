@@ -1,8 +1,7 @@
 package metric_test
 
 import (
-	// "reflect"
-	"fmt"
+	"math"
 	"testing"
 
 	"github.com/sugarme/gotch/ts"
@@ -10,47 +9,139 @@ import (
 	"github.com/sugarme/iseg/metric"
 )
 
-func TestDiceLoss(t *testing.T) {
-	pslice := []int64{1, 0, 0, 1, 0, 0, 1, 0, 0}
-	tslice := []int64{1, 0, 0, 1, 1, 0, 1, 0, 0}
+var (
+	// 3 overlapping pixels, |P| = 3, |T| = 4.
+	lossPred   = []int64{1, 0, 0, 1, 0, 0, 1, 0, 0}
+	lossTarget = []int64{1, 0, 0, 1, 1, 0, 1, 0, 0}
+	lossEmpty  = []int64{0, 0, 0, 0, 0, 0, 0, 0, 0}
+)
 
-	pred := ts.MustOfSlice(pslice).MustView([]int64{1, 3, 3}, true)
-	target := ts.MustOfSlice(tslice).MustView([]int64{1, 3, 3}, true)
+// batch stacks samples of 3x3 into a tensor of shape [B, 3, 3].
+func batch(samples ...[]int64) *ts.Tensor {
+	var data []int64
+	for _, s := range samples {
+		data = append(data, s...)
+	}
 
-	loss := metric.DiceCoeff(pred, target)
+	return ts.MustOfSlice(data).MustView([]int64{int64(len(samples)), 3, 3}, true)
+}
 
-	fmt.Printf("Dice Loss: %0.4f\n", loss)
+func TestDiceCoeff(t *testing.T) {
+	tests := []struct {
+		name   string
+		pred   []int64
+		target []int64
+		opts   []metric.MetricOption
+		want   float64
+	}{
+		{"overlap", lossPred, lossTarget, nil, 6.0 / 7},
+		{"smooth", lossPred, lossTarget, []metric.MetricOption{metric.WithSmooth(1)}, 7.0 / 8},
+		{"empty pred", lossEmpty, lossTarget, nil, 0},
+		{"empty default", lossEmpty, lossEmpty, nil, 1},
+		{"empty smooth", lossEmpty, lossEmpty, []metric.MetricOption{metric.WithSmooth(1)}, 1},
+		{"empty skip", lossEmpty, lossEmpty, []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptySkip)}, math.NaN()},
+		{"empty nan", lossEmpty, lossEmpty, []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyNaN)}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		got := metric.DiceCoeff(batch(tt.pred), batch(tt.target), tt.opts...)
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
 }
 
-func TestJaccardIndex(t *testing.T) {
-	pslice := []int64{1, 0, 0, 1, 0, 0, 1, 0, 0}
-	tslice := []int64{1, 0, 0, 1, 1, 0, 1, 0, 0}
+func TestDiceCoeffBatch(t *testing.T) {
+	prob := ts.MustOfSlice([]float64{0.9, 0.2, 0.1, 0.7, 0.4, 0.3, 0.6, 0.0, 0.1}).MustView([]int64{1, 3, 3}, true)
+	target := batch(lossTarget)
+	assertFloats(t, "threshold 0.5", []float64{6.0 / 7}, []float64{metric.DiceCoeffBatch(prob, target)})
+	assertFloats(t, "threshold 0.35", []float64{1}, []float64{metric.DiceCoeffBatch(prob, target, 0.35)})
+	assertFloats(t, "WithThreshold 0.35", []float64{1}, []float64{metric.DiceCoeffBatchWithOptions(prob, target, metric.WithThreshold(0.35))})
+
+	tests := []struct {
+		name   string
+		policy metric.EmptyPolicy
+		want   float64
+	}{
+		{"score one", metric.EmptyScoreOne, (6.0/7 + 1) / 2},
+		{"skip", metric.EmptySkip, 6.0 / 7},
+		{"nan", metric.EmptyNaN, math.NaN()},
+	}
+
+	pred := batch(lossPred, lossEmpty)
+	target = batch(lossTarget, lossEmpty)
+	for _, tt := range tests {
+		got := metric.DiceCoeffBatchWithOptions(pred, target, metric.WithEmptyPolicy(tt.policy))
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
+}
 
-	pred := ts.MustOfSlice(pslice).MustView([]int64{1, 3, 3}, true)
-	target := ts.MustOfSlice(tslice).MustView([]int64{1, 3, 3}, true)
+// channels stacks channels of 3x3 into a tensor of shape [1, C, 3, 3].
+func channels(planes ...[]int64) *ts.Tensor {
+	return batch(planes...).MustView([]int64{1, int64(len(planes)), 3, 3}, true)
+}
 
-	iou := metric.JaccardIndex(pred, target, 2)
-	fmt.Printf("IoU: %0.4f\n", iou)
+func TestDiceLoss(t *testing.T) {
+	tests := []struct {
+		name   string
+		pred   *ts.Tensor
+		target *ts.Tensor
+		opts   []metric.MetricOption
+		want   float64
+	}{
+		{"overlap", batch(lossPred), batch(lossTarget), nil, 1.0 / 7},
+		{"smooth", batch(lossPred), batch(lossTarget), []metric.MetricOption{metric.WithSmooth(1)}, 1.0 / 8},
+		{"empty", batch(lossEmpty), batch(lossEmpty), nil, 0},
+		{"batch score one", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), nil, 1.0 / 14},
+		{"batch skip", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptySkip)}, 1.0 / 7},
+		{"batch nan", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyNaN)}, math.NaN()},
+		// Dice per channel: 6/7 and 1. Dice pooled over channels would be 14/15.
+		{"channels", channels(lossPred, lossTarget), channels(lossTarget, lossTarget), nil, 1.0 / 14},
+		{"channels skip", channels(lossPred, lossEmpty), channels(lossTarget, lossEmpty), []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptySkip)}, 1.0 / 7},
+	}
+
+	for _, tt := range tests {
+		got := metric.DiceLoss(tt.pred, tt.target, tt.opts...)
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
 }
 
 func TestIoU(t *testing.T) {
-	pslice := []int64{1, 0, 0, 1, 0, 0, 1, 0, 0}
-	tslice := []int64{1, 0, 0, 1, 1, 0, 1, 0, 0}
-
-	pred := ts.MustOfSlice(pslice).MustView([]int64{1, 3, 3}, true)
-	target := ts.MustOfSlice(tslice).MustView([]int64{1, 3, 3}, true)
-
-	iou := metric.IoU(pred, target)
-	fmt.Printf("IoU: %0.4f\n", iou) // 0.7500
+	tests := []struct {
+		name   string
+		pred   *ts.Tensor
+		target *ts.Tensor
+		opts   []metric.MetricOption
+		want   float64
+	}{
+		{"overlap", batch(lossPred), batch(lossTarget), nil, 0.75},
+		{"smooth", batch(lossPred), batch(lossTarget), []metric.MetricOption{metric.WithSmooth(1)}, 0.8},
+		{"empty", batch(lossEmpty), batch(lossEmpty), nil, 1},
+		{"batch score one", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), nil, 0.875},
+		{"batch skip", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptySkip)}, 0.75},
+		{"batch nan", batch(lossPred, lossEmpty), batch(lossTarget, lossEmpty), []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyNaN)}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		got := metric.IoU(tt.pred, tt.target, tt.opts...)
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
 }
 
-func TestDiceCoeff(t *testing.T) {
-	pslice := []int64{1, 0, 0, 1, 0, 0, 1, 0, 0}
-	tslice := []int64{1, 0, 0, 1, 1, 0, 1, 0, 0}
-
-	pred := ts.MustOfSlice(pslice).MustView([]int64{1, 3, 3}, true)
-	target := ts.MustOfSlice(tslice).MustView([]int64{1, 3, 3}, true)
-
-	iou := metric.DiceCoeff(pred, target)
-	fmt.Printf("IoU: %0.4f\n", iou) // 0.8571
+func TestJaccardIndex(t *testing.T) {
+	// class 0: IoU 5/6, class 1: IoU 3/4, class 2: absent.
+	tests := []struct {
+		name     string
+		nclasses int64
+		opts     []metric.MetricOption
+		want     float64
+	}{
+		{"two classes", 2, nil, (5.0/6 + 0.75) / 2},
+		{"absent score one", 3, nil, (5.0/6 + 0.75 + 1) / 3},
+		{"absent skip", 3, []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptySkip)}, (5.0/6 + 0.75) / 2},
+		{"absent nan", 3, []metric.MetricOption{metric.WithEmptyPolicy(metric.EmptyNaN)}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		got := metric.JaccardIndex(batch(lossPred), batch(lossTarget), tt.nclasses, tt.opts...)
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
 }
@@ -0,0 +1,245 @@
+package metric
+
+import (
+	"sort"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// LabelComponents labels connected components of foreground pixels of a
+// binary image of size h x w. Background pixels get label 0 and components
+// are labelled 1, 2, ... in raster order.
+//
+// connectivity: Optional (default=8). Either 4 or 8 neighbourhood.
+func LabelComponents(fg []bool, h, w int, connectivityOpt ...int) (labels []int, n int) {
+	connectivity := 8
+	if len(connectivityOpt) > 0 {
+		connectivity = connectivityOpt[0]
+	}
+
+	offsets := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	if connectivity == 8 {
+		offsets = append(offsets, [2]int{-1, -1}, [2]int{-1, 1}, [2]int{1, -1}, [2]int{1, 1})
+	}
+
+	labels = make([]int, h*w)
+	var stack []int
+	for i := range fg {
+		if !fg[i] || labels[i] != 0 {
+			continue
+		}
+
+		// Flood fill a new component.
+		n++
+		labels[i] = n
+		stack = append(stack[:0], i)
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			y, x := j/w, j%w
+			for _, o := range offsets {
+				ny, nx := y+o[0], x+o[1]
+				if ny < 0 || ny >= h || nx < 0 || nx >= w {
+					continue
+				}
+				k := ny*w + nx
+				if fg[k] && labels[k] == 0 {
+					labels[k] = n
+					stack = append(stack, k)
+				}
+			}
+		}
+	}
+
+	return labels, n
+}
+
+// ObjectCounts holds object-level matching counts.
+type ObjectCounts struct {
+	TP int // matched predicted objects
+	FP int // unmatched predicted objects
+	FN int // unmatched target objects
+}
+
+// Add accumulates counts of other into c.
+func (c *ObjectCounts) Add(other ObjectCounts) {
+	c.TP += other.TP
+	c.FP += other.FP
+	c.FN += other.FN
+}
+
+// Precision returns TP / (TP + FP) or NaN if there is no predicted object.
+func (c ObjectCounts) Precision() float64 {
+	return ratio(float64(c.TP), float64(c.TP+c.FP))
+}
+
+// Recall returns TP / (TP + FN) or NaN if there is no target object.
+func (c ObjectCounts) Recall() float64 {
+	return ratio(float64(c.TP), float64(c.TP+c.FN))
+}
+
+// F1 returns 2TP / (2TP + FP + FN). If there is no object at all, it's scored
+// by empty policy (default=EmptySkip, i.e. NaN). See MetricOptions.
+func (c ObjectCounts) F1(opts ...MetricOption) float64 {
+	o := newMetricOptions(EmptySkip, opts)
+	f1, _ := o.score(float64(2*c.TP), float64(2*c.TP+c.FP+c.FN))
+	return f1
+}
+
+// objectIoUs returns IoU of every overlapping pair of labelled predicted and
+// target objects, keyed by [pred label, target label].
+func objectIoUs(predLabels, targetLabels []int, npred, ntarget int) map[[2]int]float64 {
+	predArea := make([]int, npred+1)
+	targetArea := make([]int, ntarget+1)
+	intersections := make(map[[2]int]int)
+	for i := range predLabels {
+		p, t := predLabels[i], targetLabels[i]
+		predArea[p]++
+		targetArea[t]++
+		if p > 0 && t > 0 {
+			intersections[[2]int{p, t}]++
+		}
+	}
+
+	ious := make(map[[2]int]float64, len(intersections))
+	for k, inter := range intersections {
+		union := predArea[k[0]] + targetArea[k[1]] - inter
+		ious[k] = float64(inter) / float64(union)
+	}
+
+	return ious
+}
+
+// matchObjects greedily matches objects one-to-one in order of decreasing IoU,
+// with IoU at least iouThreshold.
+func matchObjects(ious map[[2]int]float64, npred, ntarget int, iouThreshold float64) ObjectCounts {
+	type pair struct {
+		key [2]int
+		iou float64
+	}
+	var pairs []pair
+	for k, v := range ious {
+		if v >= iouThreshold {
+			pairs = append(pairs, pair{k, v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].iou != pairs[j].iou {
+			return pairs[i].iou > pairs[j].iou
+		}
+		if pairs[i].key[0] != pairs[j].key[0] {
+			return pairs[i].key[0] < pairs[j].key[0]
+		}
+		return pairs[i].key[1] < pairs[j].key[1]
+	})
+
+	predMatched := make([]bool, npred+1)
+	targetMatched := make([]bool, ntarget+1)
+	var tp int
+	for _, p := range pairs {
+		if predMatched[p.key[0]] || targetMatched[p.key[1]] {
+			continue
+		}
+		predMatched[p.key[0]] = true
+		targetMatched[p.key[1]] = true
+		tp++
+	}
+
+	return ObjectCounts{
+		TP: tp,
+		FP: npred - tp,
+		FN: ntarget - tp,
+	}
+}
+
+// MatchObjects labels connected components of binary prediction and target
+// of size h x w and matches them one-to-one by IoU. A predicted object is a
+// true positive if its IoU with a target object is at least iouThreshold.
+//
+// connectivity: Optional (default=8). Either 4 or 8 neighbourhood.
+func MatchObjects(pred, target []bool, h, w int, iouThreshold float64, connectivityOpt ...int) ObjectCounts {
+	pl, np := LabelComponents(pred, h, w, connectivityOpt...)
+	tl, nt := LabelComponents(target, h, w, connectivityOpt...)
+
+	return matchObjects(objectIoUs(pl, tl, np, nt), np, nt, iouThreshold)
+}
+
+// DefaultIoUThresholds are IoU thresholds 0.5:0.05:0.95.
+var DefaultIoUThresholds = []float64{0.5, 0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95}
+
+// ObjectAveragePrecision computes average precision of objects of binary
+// prediction and target of size h x w: mean of TP / (TP + FP + FN) over IoU
+// thresholds (see WithIoUThresholds). If both masks have no objects, it's
+// scored by empty policy (see MetricOptions).
+// Ref. https://www.kaggle.com/c/data-science-bowl-2018/overview/evaluation
+func ObjectAveragePrecision(pred, target []bool, h, w int, opts ...MetricOption) float64 {
+	score, _ := objectAveragePrecision(pred, target, h, w, NewMetricOptions(opts...))
+	return score
+}
+
+// objectAveragePrecision computes object average precision and whether it
+// should be counted in averages.
+func objectAveragePrecision(pred, target []bool, h, w int, o MetricOptions) (float64, bool) {
+	pl, np := LabelComponents(pred, h, w)
+	tl, nt := LabelComponents(target, h, w)
+	if np == 0 && nt == 0 {
+		return o.empty(1)
+	}
+
+	ious := objectIoUs(pl, tl, np, nt)
+	var sum float64
+	for _, thr := range o.IoUThresholds {
+		c := matchObjects(ious, np, nt, thr)
+		sum += float64(c.TP) / float64(c.TP+c.FP+c.FN)
+	}
+
+	return sum / float64(len(o.IoUThresholds)), true
+}
+
+// ObjectMetrics matches objects (see MatchObjects) of every sample of binary
+// prediction and target of shape [B, H, W] and returns counts summed over
+// the batch. Nonzero values are foreground.
+func ObjectMetrics(pred, target *ts.Tensor, iouThreshold float64, connectivityOpt ...int) ObjectCounts {
+	pvals, h, w := planeValues(pred)
+	tvals, _, _ := planeValues(target)
+
+	var counts ObjectCounts
+	p := make([]bool, h*w)
+	t := make([]bool, h*w)
+	for offset := 0; offset < len(pvals); offset += h * w {
+		for i := range p {
+			p[i] = pvals[offset+i] != 0
+			t[i] = tvals[offset+i] != 0
+		}
+		counts.Add(MatchObjects(p, t, h, w, iouThreshold, connectivityOpt...))
+	}
+
+	return counts
+}
+
+// ObjectMAP calculates object average precision (see ObjectAveragePrecision)
+// averaged over a batch of binary prediction and target of shape [B, H, W].
+// Samples without objects are handled by empty policy (see MetricOptions).
+func ObjectMAP(pred, target *ts.Tensor, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	pvals, h, w := planeValues(pred)
+	tvals, _, _ := planeValues(target)
+
+	var (
+		scores []float64
+		oks    []bool
+	)
+	p := make([]bool, h*w)
+	t := make([]bool, h*w)
+	for offset := 0; offset < len(pvals); offset += h * w {
+		for i := range p {
+			p[i] = pvals[offset+i] != 0
+			t[i] = tvals[offset+i] != 0
+		}
+		score, ok := objectAveragePrecision(p, t, h, w, o)
+		scores = append(scores, score)
+		oks = append(oks, ok)
+	}
+
+	return meanScores(scores, oks)
+}
@@ -0,0 +1,139 @@
+package metric_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestLabelComponents(t *testing.T) {
+	fg := []bool{
+		true, false, false, true,
+		false, true, false, true,
+		false, false, false, false,
+	}
+
+	// 8-connectivity: diagonal pixels are connected.
+	labels, n := metric.LabelComponents(fg, 3, 4)
+	wantLabels := []int{
+		1, 0, 0, 2,
+		0, 1, 0, 2,
+		0, 0, 0, 0,
+	}
+	if n != 2 || !reflect.DeepEqual(wantLabels, labels) {
+		t.Errorf("Want: %v (n=2)\n", wantLabels)
+		t.Errorf("Got: %v (n=%v)\n", labels, n)
+	}
+
+	_, n = metric.LabelComponents(fg, 3, 4, 4)
+	if n != 3 {
+		t.Errorf("Want 3 components with 4-connectivity. Got: %v\n", n)
+	}
+}
+
+func TestMatchObjects(t *testing.T) {
+	// Target: 2 objects. Prediction: first object exact, second missed, one spurious.
+	target := []bool{
+		true, true, false, false, false,
+		true, true, false, true, true,
+		false, false, false, true, true,
+	}
+	pred := []bool{
+		true, true, false, false, false,
+		true, true, false, false, false,
+		false, false, false, false, true,
+	}
+
+	got := metric.MatchObjects(pred, target, 3, 5, 0.5)
+	want := metric.ObjectCounts{TP: 1, FP: 1, FN: 1}
+	if want != got {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", got)
+	}
+	if math.Abs(got.F1()-0.5) > 1e-9 {
+		t.Errorf("Want F1: 0.5. Got: %v\n", got.F1())
+	}
+
+	// Lower threshold: spurious object (IoU = 0.25) matches the second target object.
+	got = metric.MatchObjects(pred, target, 3, 5, 0.2)
+	want = metric.ObjectCounts{TP: 2, FP: 0, FN: 0}
+	if want != got {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", got)
+	}
+}
+
+func TestObjectAveragePrecision(t *testing.T) {
+	target := []bool{
+		true, true, false, false,
+		true, true, false, false,
+	}
+	// IoU = 0.75
+	pred := []bool{
+		true, true, false, false,
+		true, false, false, false,
+	}
+
+	// Matched at thresholds 0.5, 0.55, ..., 0.75 (6 of 10).
+	got := metric.ObjectAveragePrecision(pred, target, 2, 4)
+	if math.Abs(got-0.6) > 1e-9 {
+		t.Errorf("Want: 0.6\n")
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Single threshold
+	got = metric.ObjectAveragePrecision(pred, target, 2, 4, metric.WithIoUThresholds(0.8))
+	if got != 0 {
+		t.Errorf("Want 0 at IoU threshold 0.8. Got: %v\n", got)
+	}
+
+	empty := make([]bool, 8)
+	if got := metric.ObjectAveragePrecision(empty, empty, 2, 4); got != 1 {
+		t.Errorf("Want 1 for empty masks. Got: %v\n", got)
+	}
+	if got := metric.ObjectAveragePrecision(empty, empty, 2, 4, metric.WithEmptyPolicy(metric.EmptyNaN)); !math.IsNaN(got) {
+		t.Errorf("Want NaN for empty masks. Got: %v\n", got)
+	}
+}
+
+func TestObjectMAP(t *testing.T) {
+	// sample 1: missed object (0.0), sample 2: no objects
+	pred := ts.MustOfSlice([]int64{0, 0, 0, 0, 0, 0, 0, 0}).MustView([]int64{2, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{1, 0, 0, 1, 0, 0, 0, 0}).MustView([]int64{2, 2, 2}, true)
+
+	tests := []struct {
+		name   string
+		policy metric.EmptyPolicy
+		want   float64
+	}{
+		{"score one", metric.EmptyScoreOne, 0.5},
+		{"skip", metric.EmptySkip, 0},
+		{"nan", metric.EmptyNaN, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		got := metric.ObjectMAP(pred, target, metric.WithEmptyPolicy(tt.policy))
+		assertFloats(t, tt.name, []float64{tt.want}, []float64{got})
+	}
+
+	var counts metric.ObjectCounts
+	if !math.IsNaN(counts.F1()) || counts.F1(metric.WithEmptyPolicy(metric.EmptyScoreOne)) != 1 {
+		t.Errorf("Want F1 of no objects NaN by default and 1 with EmptyScoreOne.")
+	}
+}
+
+func TestObjectMetrics(t *testing.T) {
+	pred := ts.MustOfSlice([]int64{1, 0, 0, 1, 1, 0, 0, 0}).MustView([]int64{2, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{1, 0, 0, 1, 0, 0, 0, 1}).MustView([]int64{2, 2, 2}, true)
+
+	got := metric.ObjectMetrics(pred, target, 0.5)
+	want := metric.ObjectCounts{TP: 1, FP: 1, FN: 1}
+	if want != got {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", got)
+	}
+}
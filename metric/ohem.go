@@ -0,0 +1,147 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// OHEMOptions holds optional settings for OHEM.
+type OHEMOptions struct {
+	TopK      int64      // number of hardest pixels to keep. If 0, KeepRatio is used.
+	KeepRatio float64    // fraction of hardest pixels to keep when TopK = 0.
+	Threshold float64    // if > 0, keep all pixels with loss above it instead of top-k.
+	MinKept   int64      // minimum number of pixels to keep.
+	Valid     *ts.Tensor // optional boolean mask of pixels to consider, same shape as loss.
+}
+
+type OHEMOption func(*OHEMOptions)
+
+func NewOHEMOptions(options ...OHEMOption) OHEMOptions {
+	opts := OHEMOptions{
+		TopK:      0,
+		KeepRatio: 0.25,
+		Threshold: 0.0,
+		MinKept:   0,
+		Valid:     nil,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithTopK(k int64) OHEMOption {
+	return func(o *OHEMOptions) {
+		o.TopK = k
+	}
+}
+
+func WithKeepRatio(ratio float64) OHEMOption {
+	return func(o *OHEMOptions) {
+		o.KeepRatio = ratio
+	}
+}
+
+func WithLossThreshold(threshold float64) OHEMOption {
+	return func(o *OHEMOptions) {
+		o.Threshold = threshold
+	}
+}
+
+func WithMinKept(n int64) OHEMOption {
+	return func(o *OHEMOptions) {
+		o.MinKept = n
+	}
+}
+
+func WithValidMask(valid *ts.Tensor) OHEMOption {
+	return func(o *OHEMOptions) {
+		o.Valid = valid
+	}
+}
+
+// OHEM performs online hard example mining on a per-pixel loss (i.e. loss
+// calculated with ReductionNone) and returns mean loss of the hardest pixels.
+//
+// By default, it keeps top-k pixels with highest loss. If a loss threshold
+// is specified, it keeps all pixels with loss above the threshold, falling back
+// to top MinKept pixels when there are fewer than MinKept of them.
+// Ref. https://arxiv.org/abs/1604.03540
+func OHEM(loss *ts.Tensor, opts ...OHEMOption) *ts.Tensor {
+	o := NewOHEMOptions(opts...)
+
+	var flat *ts.Tensor
+	if o.Valid != nil {
+		flat = loss.MustMaskedSelect(o.Valid, false)
+	} else {
+		flat = loss.MustReshape([]int64{-1}, false)
+	}
+
+	n := flat.MustSize()[0]
+	minKept := o.MinKept
+	if minKept > n {
+		minKept = n
+	}
+
+	var k int64
+	switch {
+	case o.Threshold > 0:
+		hard := flat.MustGt(ts.FloatScalar(o.Threshold), false)
+		nhardTs := hard.MustSum(gotch.Int64, false)
+		nhard := nhardTs.Int64Values()[0]
+		nhardTs.MustDrop()
+
+		if nhard > 0 && nhard >= minKept {
+			kept := flat.MustMaskedSelect(hard, true)
+			hard.MustDrop()
+			return kept.MustMean(loss.DType(), true)
+		}
+		hard.MustDrop()
+		k = minKept
+
+	case o.TopK > 0:
+		k = o.TopK
+
+	default:
+		k = int64(math.Ceil(o.KeepRatio * float64(n)))
+	}
+
+	if k < minKept {
+		k = minKept
+	}
+	if k > n {
+		k = n
+	}
+
+	// Nothing to keep: return zero loss still attached to the graph.
+	if k == 0 {
+		return flat.MustSum(loss.DType(), true).MustMulScalar(ts.FloatScalar(0.0), true)
+	}
+
+	values, indices := flat.MustTopK(k, 0, true, false)
+	indices.MustDrop()
+	flat.MustDrop()
+
+	return values.MustMean(loss.DType(), true)
+}
+
+// OHEMCrossEntropyLoss calculates cross entropy loss (see CrossEntropyLoss)
+// averaged over the hardest pixels only. Pixels with ignore index are excluded
+// from mining.
+func OHEMCrossEntropyLoss(logits, mask *ts.Tensor, ceOpts []CrossEntropyOption, ohemOpts ...OHEMOption) *ts.Tensor {
+	ceo := NewCrossEntropyOptions(ceOpts...)
+	noReduction := append(append([]CrossEntropyOption{}, ceOpts...), WithReduction(ReductionNone))
+	loss := CrossEntropyLoss(logits, mask, noReduction...)
+
+	valid := mask.MustNe(ts.IntScalar(ceo.IgnoreIndex), false)
+	withValid := append(append([]OHEMOption{}, ohemOpts...), WithValidMask(valid))
+	retVal := OHEM(loss, withValid...)
+	valid.MustDrop()
+	loss.MustDrop()
+
+	return retVal
+}
@@ -0,0 +1,64 @@
+package metric_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestOHEM(t *testing.T) {
+	loss := ts.MustOfSlice([]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8}).MustView([]int64{1, 2, 4}, true)
+
+	tests := []struct {
+		name string
+		opts []metric.OHEMOption
+		want float64
+	}{
+		{"default ratio", nil, 0.75},
+		{"topk", []metric.OHEMOption{metric.WithTopK(4)}, 0.65},
+		{"threshold", []metric.OHEMOption{metric.WithLossThreshold(0.55)}, 0.7},
+		{"threshold min kept", []metric.OHEMOption{metric.WithLossThreshold(0.75), metric.WithMinKept(2)}, 0.75},
+		{"min kept over ratio", []metric.OHEMOption{metric.WithKeepRatio(0.1), metric.WithMinKept(3)}, 0.7},
+		{"nothing kept", []metric.OHEMOption{metric.WithLossThreshold(1.0)}, 0.0},
+	}
+
+	for _, tt := range tests {
+		out := metric.OHEM(loss, tt.opts...)
+		got := out.Float64Values()[0]
+		out.MustDrop()
+		if math.Abs(tt.want-got) > 1e-9 {
+			t.Errorf("%v - Want: %v\n", tt.name, tt.want)
+			t.Errorf("%v - Got: %v\n", tt.name, got)
+		}
+	}
+}
+
+func TestOHEM_ValidMask(t *testing.T) {
+	loss := ts.MustOfSlice([]float64{0.9, 0.2, 0.3, 0.4})
+	valid := ts.MustOfSlice([]bool{false, true, true, true})
+
+	out := metric.OHEM(loss, metric.WithTopK(1), metric.WithValidMask(valid))
+	got := out.Float64Values()[0]
+	if math.Abs(got-0.4) > 1e-9 {
+		t.Errorf("Want: %v\n", 0.4)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestOHEMCrossEntropyLoss(t *testing.T) {
+	logits := ts.MustZeros([]int64{1, 2, 2, 2}, gotch.Float, gotch.CPU)
+	mask := ts.MustOfSlice([]int64{0, 1, 255, 255}).MustView([]int64{1, 2, 2}, true)
+
+	ceOpts := []metric.CrossEntropyOption{metric.WithIgnoreIndex(255)}
+	loss := metric.OHEMCrossEntropyLoss(logits, mask, ceOpts, metric.WithTopK(4))
+	got := loss.Float64Values()[0]
+	want := math.Log(2)
+	if math.Abs(want-got) > 1e-5 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,165 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// EmptyPolicy specifies how a score is defined for a sample (or a class)
+// where both prediction and target are empty.
+type EmptyPolicy int
+
+const (
+	EmptyScoreOne EmptyPolicy = iota // perfect score: 1 (loss: 0, distance: 0)
+	EmptySkip                        // NaN, excluded from average
+	EmptyNaN                         // NaN, which propagates to average
+)
+
+// MetricOptions holds optional settings shared by metric functions.
+//
+// Empty prediction and target are scored by empty policy everywhere. Its
+// default is EmptyScoreOne for per-sample metrics (DiceCoeff,
+// DiceCoeffBatchWithOptions, DiceLoss, IoU, JaccardIndex, BoundaryF1,
+// BoundaryIoU, HD95, ASSD and ObjectAveragePrecision) and EmptySkip for
+// metrics accumulated over a dataset (ConfusionMatrix, Report, ObjectCounts,
+// ThresholdSweep and PanopticQuality), so that absent classes don't inflate averages.
+type MetricOptions struct {
+	Smooth        float64     // added to both numerator and denominator of overlap scores
+	Empty         EmptyPolicy // score of empty prediction and empty target
+	Threshold     float64     // probability threshold to binarize prediction (DiceCoeffBatchWithOptions)
+	Spacing       []float64   // pixel spacing along rows and columns (HD95, ASSD)
+	IoUThresholds []float64   // object matching IoU thresholds (ObjectAveragePrecision, ObjectMAP)
+}
+
+type MetricOption func(*MetricOptions)
+
+func NewMetricOptions(options ...MetricOption) MetricOptions {
+	return newMetricOptions(EmptyScoreOne, options)
+}
+
+// newMetricOptions creates MetricOptions with a metric specific default empty policy.
+func newMetricOptions(empty EmptyPolicy, options []MetricOption) MetricOptions {
+	opts := MetricOptions{
+		Smooth:        0.0,
+		Empty:         empty,
+		Threshold:     0.5,
+		IoUThresholds: DefaultIoUThresholds,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithSmooth(smooth float64) MetricOption {
+	return func(o *MetricOptions) {
+		o.Smooth = smooth
+	}
+}
+
+func WithEmptyPolicy(policy EmptyPolicy) MetricOption {
+	return func(o *MetricOptions) {
+		o.Empty = policy
+	}
+}
+
+func WithThreshold(threshold float64) MetricOption {
+	return func(o *MetricOptions) {
+		o.Threshold = threshold
+	}
+}
+
+// WithSpacing sets pixel spacing along rows and columns. A single value
+// applies to both. Default is 1.0.
+func WithSpacing(spacing ...float64) MetricOption {
+	return func(o *MetricOptions) {
+		o.Spacing = spacing
+	}
+}
+
+// WithIoUThresholds sets IoU thresholds of object matching. Default is DefaultIoUThresholds.
+func WithIoUThresholds(thresholds ...float64) MetricOption {
+	return func(o *MetricOptions) {
+		o.IoUThresholds = thresholds
+	}
+}
+
+// empty returns score of empty prediction and target as specified by empty
+// policy given the score of a perfect match (e.g. 1 for overlap, 0 for
+// distance). ok is false if it should be skipped.
+func (o MetricOptions) empty(perfect float64) (score float64, ok bool) {
+	switch o.Empty {
+	case EmptySkip:
+		return math.NaN(), false
+	case EmptyNaN:
+		return math.NaN(), true
+	default:
+		return perfect, true
+	}
+}
+
+// score returns smoothed ratio (num + smooth) / (den + smooth).
+// If den is zero (i.e. empty prediction and target), score is defined by
+// empty policy and ok is false if it should be skipped.
+func (o MetricOptions) score(num, den float64) (score float64, ok bool) {
+	if den == 0 {
+		return o.empty(1)
+	}
+
+	return (num + o.Smooth) / (den + o.Smooth), true
+}
+
+// scores returns score of every sample (or class) given their numerators and
+// denominators. Skipped ones are NaN.
+func (o MetricOptions) scores(nums, dens []float64) []float64 {
+	scores := make([]float64, len(nums))
+	for i := range nums {
+		scores[i], _ = o.score(nums[i], dens[i])
+	}
+
+	return scores
+}
+
+// mean returns mean of scores of samples (or classes) given their numerators
+// and denominators. It returns NaN if all of them are skipped.
+func (o MetricOptions) mean(nums, dens []float64) float64 {
+	scores := make([]float64, len(nums))
+	oks := make([]bool, len(nums))
+	for i := range nums {
+		scores[i], oks[i] = o.score(nums[i], dens[i])
+	}
+
+	return meanScores(scores, oks)
+}
+
+// meanScores returns mean of scores where ok is true. It returns NaN if none is.
+func meanScores(scores []float64, oks []bool) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for i, s := range scores {
+		if !oks[i] {
+			continue
+		}
+		sum += s
+		n++
+	}
+
+	return ratio(sum, float64(n))
+}
+
+// sampleSums returns sums of x over all dimensions but the first, i.e. a
+// value per sample of a batch.
+func sampleSums(x *ts.Tensor) []float64 {
+	bs := x.MustSize()[0]
+	s := x.MustReshape([]int64{bs, -1}, false).MustSumDimIntlist([]int64{1}, false, gotch.Double, true)
+	vals := s.Float64Values()
+	s.MustDrop()
+
+	return vals
+}
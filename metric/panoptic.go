@@ -0,0 +1,255 @@
+package metric
+
+import (
+	"log"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// PanopticQuality accumulates panoptic segmentation matches over images to
+// compute panoptic quality (PQ), segmentation quality (SQ) and recognition
+// quality (RQ).
+//
+// A segment is identified by a pair of semantic class and instance ID. Stuff
+// classes (i.e. not thing classes) have a single segment per image regardless
+// of instance IDs. A predicted and a target segment of the same class match if
+// their IoU > 0.5.
+// Ref. https://arxiv.org/abs/1801.00868
+type PanopticQuality struct {
+	nclasses    int64
+	things      []bool
+	ignoreIndex int64
+	hasIgnore   bool
+	tp          []float64
+	fp          []float64
+	fn          []float64
+	iouSum      []float64
+}
+
+// NewPanopticQuality creates a new PanopticQuality.
+//
+// nclasses: number of semantic classes.
+// thingClasses: classes with countable instances. Other classes are stuff.
+// ignoreIndex: Optional. Target semantic value of void pixels (e.g. 255).
+func NewPanopticQuality(nclasses int64, thingClasses []int64, ignoreIndexOpt ...int64) *PanopticQuality {
+	pq := &PanopticQuality{
+		nclasses: nclasses,
+		things:   make([]bool, nclasses),
+		tp:       make([]float64, nclasses),
+		fp:       make([]float64, nclasses),
+		fn:       make([]float64, nclasses),
+		iouSum:   make([]float64, nclasses),
+	}
+	for _, c := range thingClasses {
+		if c < 0 || c >= nclasses {
+			log.Fatalf("Thing class out of range [0, %v). Got: %v\n", nclasses, c)
+		}
+		pq.things[c] = true
+	}
+	if len(ignoreIndexOpt) > 0 {
+		pq.ignoreIndex = ignoreIndexOpt[0]
+		pq.hasIgnore = true
+	}
+
+	return pq
+}
+
+type segmentID struct {
+	class    int
+	instance int
+}
+
+// segment returns segment ID of a pixel and whether it belongs to any segment.
+func (pq *PanopticQuality) segment(class, instance int) (segmentID, bool) {
+	if class < 0 || int64(class) >= pq.nclasses {
+		return segmentID{}, false
+	}
+	if !pq.things[class] {
+		instance = 0
+	}
+
+	return segmentID{class, instance}, true
+}
+
+// Add adds an image given as flat semantic and instance ID maps of prediction
+// and target, all of the same length.
+//
+// Target pixels equal to ignore index or out of class range are void: they
+// are excluded from IoU and predicted segments mostly (> 50%) in void are not
+// counted as false positives. Predicted pixels out of class range are unlabelled.
+func (pq *PanopticQuality) Add(predSemantic, predInstance, targetSemantic, targetInstance []int) {
+	n := len(predSemantic)
+	if len(predInstance) != n || len(targetSemantic) != n || len(targetInstance) != n {
+		log.Fatalf("Expected ID maps of the same length. Got: %v, %v, %v, %v\n", n, len(predInstance), len(targetSemantic), len(targetInstance))
+	}
+
+	predArea := make(map[segmentID]float64)
+	targetArea := make(map[segmentID]float64)
+	predVoid := make(map[segmentID]float64) // predicted pixels on void target
+	intersections := make(map[[2]segmentID]float64)
+	for i := 0; i < n; i++ {
+		pseg, hasPred := pq.segment(predSemantic[i], predInstance[i])
+		void := pq.hasIgnore && int64(targetSemantic[i]) == pq.ignoreIndex
+		tseg, hasTarget := pq.segment(targetSemantic[i], targetInstance[i])
+		hasTarget = hasTarget && !void
+
+		if hasPred {
+			predArea[pseg]++
+			if !hasTarget {
+				predVoid[pseg]++
+			}
+		}
+		if hasTarget {
+			targetArea[tseg]++
+		}
+		if hasPred && hasTarget && pseg.class == tseg.class {
+			intersections[[2]segmentID{pseg, tseg}]++
+		}
+	}
+
+	// NOTE. Matches with IoU > 0.5 are unique, so no assignment is needed.
+	predMatched := make(map[segmentID]bool)
+	targetMatched := make(map[segmentID]bool)
+	for k, inter := range intersections {
+		union := predArea[k[0]] + targetArea[k[1]] - inter - predVoid[k[0]]
+		iou := inter / union
+		if iou <= 0.5 {
+			continue
+		}
+		c := k[0].class
+		pq.tp[c]++
+		pq.iouSum[c] += iou
+		predMatched[k[0]] = true
+		targetMatched[k[1]] = true
+	}
+
+	for s := range targetArea {
+		if !targetMatched[s] {
+			pq.fn[s.class]++
+		}
+	}
+	for s, area := range predArea {
+		if predMatched[s] || predVoid[s]/area > 0.5 {
+			continue
+		}
+		pq.fp[s.class]++
+	}
+}
+
+// Update adds a batch of prediction and target semantic and instance ID maps,
+// all of shape [B, H, W] (or [H, W]).
+func (pq *PanopticQuality) Update(predSemantic, predInstance, targetSemantic, targetInstance *ts.Tensor) {
+	ps, h, w := planeValues(predSemantic)
+	pi, _, _ := planeValues(predInstance)
+	tsem, _, _ := planeValues(targetSemantic)
+	ti, _, _ := planeValues(targetInstance)
+
+	size := h * w
+	toInts := func(vals []float64) []int {
+		ints := make([]int, len(vals))
+		for i, v := range vals {
+			ints[i] = int(v)
+		}
+		return ints
+	}
+	for offset := 0; offset < len(ps); offset += size {
+		pq.Add(
+			toInts(ps[offset:offset+size]),
+			toInts(pi[offset:offset+size]),
+			toInts(tsem[offset:offset+size]),
+			toInts(ti[offset:offset+size]),
+		)
+	}
+}
+
+// Reset clears all accumulated counts.
+func (pq *PanopticQuality) Reset() {
+	for i := range pq.tp {
+		pq.tp[i] = 0
+		pq.fp[i] = 0
+		pq.fn[i] = 0
+		pq.iouSum[i] = 0
+	}
+}
+
+// PanopticScore holds panoptic scores. PQ = SQ * RQ.
+type PanopticScore struct {
+	PQ float64
+	SQ float64 // mean IoU of matched segments
+	RQ float64 // F1 score of segment matching
+	TP int64
+	FP int64
+	FN int64
+}
+
+// PanopticResult holds per-class scores and their averages over all, thing
+// and stuff classes.
+type PanopticResult struct {
+	Classes []PanopticScore
+	All     PanopticScore
+	Things  PanopticScore
+	Stuff   PanopticScore
+}
+
+// Result computes panoptic scores from accumulated counts.
+//
+// Scores of a class without any segment in prediction and target are defined
+// by empty policy (default=EmptySkip, i.e. NaN and excluded from averages).
+// See MetricOptions. Averages are means of class scores.
+func (pq *PanopticQuality) Result(opts ...MetricOption) *PanopticResult {
+	o := newMetricOptions(EmptySkip, opts)
+	emptyScore, emptyOK := o.empty(1)
+	r := &PanopticResult{
+		Classes: make([]PanopticScore, pq.nclasses),
+	}
+
+	average := func(include func(c int) bool) PanopticScore {
+		var (
+			pqs, sqs, rqs []float64
+			oks           []bool
+			score         PanopticScore
+		)
+		for c, s := range r.Classes {
+			if !include(c) {
+				continue
+			}
+			score.TP += s.TP
+			score.FP += s.FP
+			score.FN += s.FN
+			pqs = append(pqs, s.PQ)
+			sqs = append(sqs, s.SQ)
+			rqs = append(rqs, s.RQ)
+			oks = append(oks, s.TP+s.FP+s.FN > 0 || emptyOK)
+		}
+		score.PQ = meanScores(pqs, oks)
+		score.SQ = meanScores(sqs, oks)
+		score.RQ = meanScores(rqs, oks)
+
+		return score
+	}
+
+	for c := range r.Classes {
+		tp, fp, fn := pq.tp[c], pq.fp[c], pq.fn[c]
+		s := PanopticScore{
+			TP: int64(tp),
+			FP: int64(fp),
+			FN: int64(fn),
+			SQ: ratio(pq.iouSum[c], tp),
+			RQ: ratio(tp, tp+0.5*fp+0.5*fn),
+			PQ: ratio(pq.iouSum[c], tp+0.5*fp+0.5*fn),
+		}
+		switch {
+		case tp+fp+fn == 0:
+			s.PQ, s.SQ, s.RQ = emptyScore, emptyScore, emptyScore
+		case tp == 0:
+			s.SQ = 0 // counted in averages as in reference implementation
+		}
+		r.Classes[c] = s
+	}
+
+	r.All = average(func(c int) bool { return true })
+	r.Things = average(func(c int) bool { return pq.things[c] })
+	r.Stuff = average(func(c int) bool { return !pq.things[c] })
+
+	return r
+}
@@ -0,0 +1,93 @@
+package metric_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+// Class 0 (road) is stuff and class 1 (car) is thing.
+var (
+	panopticTargetSem  = []int{0, 0, 1, 1, 0, 0, 1, 1}
+	panopticTargetInst = []int{0, 0, 1, 1, 0, 0, 2, 2}
+	panopticPredSem    = []int{0, 0, 1, 1, 0, 1, 1, 1}
+	panopticPredInst   = []int{0, 0, 5, 5, 0, 6, 6, 6}
+)
+
+func TestPanopticQuality_Add(t *testing.T) {
+	pq := metric.NewPanopticQuality(2, []int64{1})
+	pq.Add(panopticPredSem, panopticPredInst, panopticTargetSem, panopticTargetInst)
+	r := pq.Result()
+
+	// road: IoU 3/4. cars: IoU 1 and 2/3.
+	assertFloats(t, "Stuff", []float64{0.75, 0.75, 1}, []float64{r.Stuff.PQ, r.Stuff.SQ, r.Stuff.RQ})
+	assertFloats(t, "Things", []float64{5.0 / 6, 5.0 / 6, 1}, []float64{r.Things.PQ, r.Things.SQ, r.Things.RQ})
+	assertFloats(t, "All", []float64{(0.75 + 5.0/6) / 2}, []float64{r.All.PQ})
+	if r.Classes[1].TP != 2 {
+		t.Errorf("Want: %v\n", 2)
+		t.Errorf("Got: %v\n", r.Classes[1].TP)
+	}
+}
+
+func TestPanopticQuality_Unmatched(t *testing.T) {
+	pq := metric.NewPanopticQuality(2, []int64{1}, 255)
+
+	// Predicted car on void target is ignored.
+	pq.Add([]int{1, 1, 1, 0}, []int{3, 3, 3, 0}, []int{255, 255, 255, 0}, []int{0, 0, 0, 0})
+	r := pq.Result()
+	assertFloats(t, "Road", []float64{1}, []float64{r.Classes[0].PQ})
+	if !math.IsNaN(r.Things.PQ) {
+		t.Errorf("Want NaN PQ without thing segments.")
+	}
+
+	// Missed car (unlabelled prediction) and false car.
+	pq.Reset()
+	pq.Add([]int{-1, -1, 1, 1}, []int{0, 0, 7, 7}, []int{1, 1, 0, 0}, []int{1, 1, 0, 0})
+	r = pq.Result()
+	car := r.Classes[1]
+	if car.TP != 0 || car.FP != 1 || car.FN != 1 {
+		t.Errorf("Want: %v\n", []int64{0, 1, 1})
+		t.Errorf("Got: %v\n", []int64{car.TP, car.FP, car.FN})
+	}
+	assertFloats(t, "Car", []float64{0, 0, 0}, []float64{car.PQ, car.SQ, car.RQ})
+}
+
+func TestPanopticQuality_EmptyPolicy(t *testing.T) {
+	// Only road (stuff) segments. Car (thing) class has no segment.
+	pq := metric.NewPanopticQuality(2, []int64{1})
+	pq.Add([]int{0, 0}, []int{0, 0}, []int{0, 0}, []int{0, 0})
+
+	tests := []struct {
+		name   string
+		policy metric.EmptyPolicy
+		want   []float64 // car PQ, all PQ
+	}{
+		{"score one", metric.EmptyScoreOne, []float64{1, 1}},
+		{"skip", metric.EmptySkip, []float64{math.NaN(), 1}},
+		{"nan", metric.EmptyNaN, []float64{math.NaN(), math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		r := pq.Result(metric.WithEmptyPolicy(tt.policy))
+		assertFloats(t, tt.name, tt.want, []float64{r.Classes[1].PQ, r.All.PQ})
+	}
+}
+
+func TestPanopticQuality_Update(t *testing.T) {
+	toTensor := func(vals []int) *ts.Tensor {
+		data := make([]int64, len(vals))
+		for i, v := range vals {
+			data[i] = int64(v)
+		}
+		return ts.MustOfSlice(data).MustView([]int64{1, 2, 4}, true)
+	}
+
+	pq := metric.NewPanopticQuality(2, []int64{1})
+	pq.Update(toTensor(panopticPredSem), toTensor(panopticPredInst), toTensor(panopticTargetSem), toTensor(panopticTargetInst))
+	r := pq.Result()
+
+	assertFloats(t, "All", []float64{(0.75 + 5.0/6) / 2}, []float64{r.All.PQ})
+}
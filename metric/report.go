@@ -0,0 +1,239 @@
+package metric
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ClassScore holds evaluation scores of a single class.
+type ClassScore struct {
+	Name      string
+	IoU       float64
+	F1        float64
+	Precision float64
+	Recall    float64
+	Support   int64 // number of target pixels
+}
+
+// Summary holds averaged scores over classes.
+type Summary struct {
+	IoU       float64
+	F1        float64
+	Precision float64
+	Recall    float64
+}
+
+// Report is a per-class evaluation report with macro (mean over classes)
+// and micro (from total counts) averages. Undefined precision and recall
+// are NaN and are skipped in macro averages. IoU and F1 of empty classes
+// follow empty policy (see NewReportWithOptions).
+type Report struct {
+	Classes       []ClassScore
+	Macro         Summary
+	Micro         Summary
+	PixelAccuracy float64
+}
+
+// NewReport creates a Report from an accumulated ConfusionMatrix.
+//
+// classNames: Optional. Names of classes. If not specified, classes are named
+// by their indices.
+func NewReport(cm *ConfusionMatrix, classNames ...string) (*Report, error) {
+	return NewReportWithOptions(cm, classNames)
+}
+
+// NewReportWithOptions creates a Report from an accumulated ConfusionMatrix.
+// IoU and F1 of classes absent from both prediction and target are scored by
+// empty policy (default=EmptySkip). See MetricOptions.
+//
+// classNames: names of classes. If empty, classes are named by their indices.
+func NewReportWithOptions(cm *ConfusionMatrix, classNames []string, opts ...MetricOption) (*Report, error) {
+	o := newMetricOptions(EmptySkip, opts)
+	n := int(cm.NClasses())
+	if len(classNames) > 0 && len(classNames) != n {
+		err := fmt.Errorf("Expected %v class names. Got: %v\n", n, len(classNames))
+		return nil, err
+	}
+
+	iou := cm.IoU(opts...)
+	f1 := cm.Dice(opts...)
+	precision := cm.Precision()
+	recall := cm.Recall()
+	support := cm.Support()
+
+	classes := make([]ClassScore, n)
+	for i := 0; i < n; i++ {
+		name := strconv.Itoa(i)
+		if len(classNames) > 0 {
+			name = classNames[i]
+		}
+		classes[i] = ClassScore{
+			Name:      name,
+			IoU:       iou[i],
+			F1:        f1[i],
+			Precision: precision[i],
+			Recall:    recall[i],
+			Support:   support[i],
+		}
+	}
+
+	// NOTE. For single-label segmentation, total FP equals total FN, hence
+	// micro precision, recall and F1 all equal pixel accuracy.
+	tp, fp, fn := cm.stats()
+	var tpSum, fpSum, fnSum float64
+	for i := range tp {
+		tpSum += tp[i]
+		fpSum += fp[i]
+		fnSum += fn[i]
+	}
+
+	microIoU, _ := o.score(tpSum, tpSum+fpSum+fnSum)
+	microF1, _ := o.score(2*tpSum, 2*tpSum+fpSum+fnSum)
+
+	return &Report{
+		Classes: classes,
+		Macro: Summary{
+			IoU:       cm.MeanIoU(opts...),
+			F1:        cm.MeanDice(opts...),
+			Precision: nanMean(precision),
+			Recall:    nanMean(recall),
+		},
+		Micro: Summary{
+			IoU:       microIoU,
+			F1:        microF1,
+			Precision: ratio(tpSum, tpSum+fpSum),
+			Recall:    ratio(tpSum, tpSum+fnSum),
+		},
+		PixelAccuracy: cm.PixelAccuracy(),
+	}, nil
+}
+
+// TotalSupport returns total number of counted target pixels.
+func (r *Report) TotalSupport() int64 {
+	var total int64
+	for _, c := range r.Classes {
+		total += c.Support
+	}
+	return total
+}
+
+// nullable converts NaN to nil so that it can be encoded to JSON.
+func nullable(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+type jsonScore struct {
+	Name      string   `json:"name,omitempty"`
+	IoU       *float64 `json:"iou"`
+	F1        *float64 `json:"f1"`
+	Precision *float64 `json:"precision"`
+	Recall    *float64 `json:"recall"`
+	Support   int64    `json:"support"`
+}
+
+type jsonReport struct {
+	Classes       []jsonScore `json:"classes"`
+	Macro         jsonScore   `json:"macro"`
+	Micro         jsonScore   `json:"micro"`
+	PixelAccuracy *float64    `json:"pixel_accuracy"`
+}
+
+func summaryScore(s Summary, support int64) jsonScore {
+	return jsonScore{
+		IoU:       nullable(s.IoU),
+		F1:        nullable(s.F1),
+		Precision: nullable(s.Precision),
+		Recall:    nullable(s.Recall),
+		Support:   support,
+	}
+}
+
+// MarshalJSON implements json.Marshaler. Undefined (NaN) scores are encoded as null.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	jr := jsonReport{
+		Classes:       make([]jsonScore, len(r.Classes)),
+		Macro:         summaryScore(r.Macro, r.TotalSupport()),
+		Micro:         summaryScore(r.Micro, r.TotalSupport()),
+		PixelAccuracy: nullable(r.PixelAccuracy),
+	}
+	for i, c := range r.Classes {
+		jr.Classes[i] = jsonScore{
+			Name:      c.Name,
+			IoU:       nullable(c.IoU),
+			F1:        nullable(c.F1),
+			Precision: nullable(c.Precision),
+			Recall:    nullable(c.Recall),
+			Support:   c.Support,
+		}
+	}
+
+	return json.Marshal(jr)
+}
+
+// WriteJSON writes report to w in indented JSON format.
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteCSV writes report to w in CSV format with a header row, one row
+// per class and 2 last rows of macro and micro averages. Undefined (NaN)
+// scores are written as empty fields, as null in JSON.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{
+		{"class", "iou", "f1", "precision", "recall", "support"},
+	}
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, c := range r.Classes {
+		records = append(records, []string{c.Name, format(c.IoU), format(c.F1), format(c.Precision), format(c.Recall), strconv.FormatInt(c.Support, 10)})
+	}
+	total := strconv.FormatInt(r.TotalSupport(), 10)
+	records = append(records, []string{"macro", format(r.Macro.IoU), format(r.Macro.F1), format(r.Macro.Precision), format(r.Macro.Recall), total})
+	records = append(records, []string{"micro", format(r.Micro.IoU), format(r.Micro.F1), format(r.Micro.Precision), format(r.Micro.Recall), total})
+
+	return cw.WriteAll(records)
+}
+
+// String implements fmt.Stringer. It prints report as an aligned text table.
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return "-"
+		}
+		return fmt.Sprintf("%.4f", v)
+	}
+
+	fmt.Fprintln(tw, "class\tIoU\tF1\tprecision\trecall\tsupport\t")
+	for _, c := range r.Classes {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t\n", c.Name, format(c.IoU), format(c.F1), format(c.Precision), format(c.Recall), c.Support)
+	}
+	total := r.TotalSupport()
+	fmt.Fprintf(tw, "macro\t%v\t%v\t%v\t%v\t%v\t\n", format(r.Macro.IoU), format(r.Macro.F1), format(r.Macro.Precision), format(r.Macro.Recall), total)
+	fmt.Fprintf(tw, "micro\t%v\t%v\t%v\t%v\t%v\t\n", format(r.Micro.IoU), format(r.Micro.F1), format(r.Micro.Precision), format(r.Micro.Recall), total)
+	tw.Flush()
+
+	fmt.Fprintf(&buf, "pixel accuracy: %v\n", format(r.PixelAccuracy))
+
+	return buf.String()
+}
@@ -0,0 +1,111 @@
+package metric_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func newTestMatrix() *metric.ConfusionMatrix {
+	cm := metric.NewConfusionMatrix(3)
+	pred := ts.MustOfSlice([]int64{0, 0, 1, 1}).MustView([]int64{1, 2, 2}, true)
+	target := ts.MustOfSlice([]int64{0, 1, 1, 1}).MustView([]int64{1, 2, 2}, true)
+	cm.Update(pred, target)
+
+	return cm
+}
+
+func TestNewReport(t *testing.T) {
+	cm := newTestMatrix()
+
+	_, err := metric.NewReport(cm, "background", "cat")
+	if err == nil {
+		t.Errorf("Expected invalid number of class names error. Got nil.")
+	}
+
+	r, err := metric.NewReport(cm, "background", "cat", "dog")
+	if err != nil {
+		t.Errorf("Unexpected error. Got: %v\n", err)
+	}
+
+	// "dog" class never appears
+	assertFloats(t, "IoU", []float64{0.5, 2.0 / 3}, []float64{r.Classes[0].IoU, r.Classes[1].IoU})
+	assertFloats(t, "Macro IoU", []float64{(0.5 + 2.0/3) / 2}, []float64{r.Macro.IoU})
+	assertFloats(t, "Micro F1", []float64{0.75}, []float64{r.Micro.F1})
+	if r.TotalSupport() != 4 {
+		t.Errorf("Want total support: 4\n")
+		t.Errorf("Got total support: %v\n", r.TotalSupport())
+	}
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	r, err := metric.NewReport(newTestMatrix(), "background", "cat", "dog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Errorf("Unexpected error. Got: %v\n", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error. Got: %v\n", err)
+	}
+
+	dog := got["classes"].([]interface{})[2].(map[string]interface{})
+	if dog["name"] != "dog" || dog["iou"] != nil {
+		t.Errorf("Want undefined score of class 'dog' encoded as null. Got: %v\n", dog)
+	}
+}
+
+func TestReport_WriteCSV(t *testing.T) {
+	r, err := metric.NewReport(newTestMatrix())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Errorf("Unexpected error. Got: %v\n", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error. Got: %v\n", err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("Want 6 rows. Got: %v\n", len(records))
+	}
+
+	want := []string{"class", "iou", "f1", "precision", "recall", "support"}
+	if !reflect.DeepEqual(want, records[0]) {
+		t.Errorf("Want header: %v\n", want)
+		t.Errorf("Got header: %v\n", records[0])
+	}
+	if dog := records[3]; dog[0] != "2" || dog[1] != "" {
+		t.Errorf("Want undefined score of class 2 written as empty field. Got: %v\n", dog)
+	}
+}
+
+func TestReport_String(t *testing.T) {
+	r, err := metric.NewReport(newTestMatrix(), "background", "cat", "dog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := r.String()
+	for _, s := range []string{"background", "macro", "micro", "pixel accuracy"} {
+		if !strings.Contains(table, s) {
+			t.Errorf("Expected table to contain %q. Got:\n%v\n", s, table)
+		}
+	}
+}
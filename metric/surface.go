@@ -0,0 +1,217 @@
+package metric
+
+import (
+	"log"
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// Surface returns surface (boundary) pixels of a binary image of size h x w:
+// foreground pixels with at least one 4-connected background neighbour.
+// Pixels outside the image are treated as background.
+func Surface(fg []bool, h, w int) []bool {
+	surface := make([]bool, h*w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if !fg[i] {
+				continue
+			}
+			if y == 0 || y == h-1 || x == 0 || x == w-1 ||
+				!fg[i-w] || !fg[i+w] || !fg[i-1] || !fg[i+1] {
+				surface[i] = true
+			}
+		}
+	}
+
+	return surface
+}
+
+// SurfaceDistances returns distances from every surface pixel of a to the
+// nearest surface pixel of b (aToB) and vice versa (bToA).
+//
+// spacing: Optional (default=1.0). Pixel spacing along rows and columns.
+func SurfaceDistances(a, b []bool, h, w int, spacing ...float64) (aToB, bToA []float64) {
+	sa := Surface(a, h, w)
+	sb := Surface(b, h, w)
+
+	da := EuclideanDistance(sa, h, w, spacing...) // distance to surface of a
+	db := EuclideanDistance(sb, h, w, spacing...) // distance to surface of b
+	for i := range sa {
+		if sa[i] {
+			aToB = append(aToB, db[i])
+		}
+		if sb[i] {
+			bToA = append(bToA, da[i])
+		}
+	}
+
+	return aToB, bToA
+}
+
+// percentile returns q-th percentile (q in [0, 100]) of values using linear
+// interpolation (same as numpy.percentile default). Values are sorted in place.
+func percentile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(values)
+
+	pos := q / 100 * float64(len(values)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	frac := pos - float64(lo)
+
+	return values[lo] + (values[hi]-values[lo])*frac
+}
+
+// checkSize exits if binary images pred and target are not of size h x w.
+func checkSize(pred, target []bool, h, w int) {
+	if len(pred) != h*w || len(target) != h*w {
+		log.Fatalf("Expected binary images of size %v x %v. Got: %v and %v pixels\n", h, w, len(pred), len(target))
+	}
+}
+
+// emptySurfaceDistance returns distance when at least one of the binary
+// images has no foreground: +Inf if only one is empty, and as specified by
+// empty policy if both are (0 for EmptyScoreOne).
+func emptySurfaceDistance(pred, target []bool, o MetricOptions) (float64, bool) {
+	var hasPred, hasTarget bool
+	for i := range pred {
+		hasPred = hasPred || pred[i]
+		hasTarget = hasTarget || target[i]
+	}
+
+	switch {
+	case !hasPred && !hasTarget:
+		d, _ := o.empty(0)
+		return d, true
+	case !hasPred || !hasTarget:
+		return math.Inf(1), true
+	default:
+		return 0, false
+	}
+}
+
+// HD95 computes 95th percentile Hausdorff distance between binary prediction
+// and target of size h x w. It's the maximum of 95th percentiles of directed
+// distances between surface pixels, from prediction to target and vice versa.
+//
+// It returns +Inf if only one of the masks is empty. Empty prediction and
+// target are scored by empty policy (0 by default). Pixel spacing is set by
+// WithSpacing (default=1.0). See MetricOptions.
+//
+// NOTE. It follows MONAI compute_hausdorff_distance (percentile=95). Medpy hd95
+// takes 95th percentile of both directed distances pooled together instead,
+// which gives different values.
+// Ref. https://github.com/Project-MONAI/MONAI/blob/dev/monai/metrics/hausdorff_distance.py
+func HD95(pred, target []bool, h, w int, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	checkSize(pred, target, h, w)
+	if d, empty := emptySurfaceDistance(pred, target, o); empty {
+		return d
+	}
+
+	pt, tp := SurfaceDistances(pred, target, h, w, o.Spacing...)
+	return math.Max(percentile(pt, 95), percentile(tp, 95))
+}
+
+// ASSD computes average symmetric surface distance between binary prediction
+// and target of size h x w: mean of average surface distances in both directions.
+//
+// It returns +Inf if only one of the masks is empty. Empty prediction and
+// target are scored by empty policy (0 by default). Pixel spacing is set by
+// WithSpacing (default=1.0). See MetricOptions.
+func ASSD(pred, target []bool, h, w int, opts ...MetricOption) float64 {
+	o := NewMetricOptions(opts...)
+	checkSize(pred, target, h, w)
+	if d, empty := emptySurfaceDistance(pred, target, o); empty {
+		return d
+	}
+
+	pt, tp := SurfaceDistances(pred, target, h, w, o.Spacing...)
+	mean := func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+
+	return (mean(pt) + mean(tp)) / 2
+}
+
+type surfaceMetric func(pred, target []bool, h, w int, opts ...MetricOption) float64
+
+// binarySurfaceMetric applies fn to every h x w plane of binary pred and target.
+func binarySurfaceMetric(fn surfaceMetric, pred, target *ts.Tensor, opts []MetricOption) []float64 {
+	pvals, h, w := planeValues(pred)
+	tvals, _, _ := planeValues(target)
+
+	var retVal []float64
+	p := make([]bool, h*w)
+	t := make([]bool, h*w)
+	for offset := 0; offset < len(pvals); offset += h * w {
+		for i := range p {
+			p[i] = pvals[offset+i] != 0
+			t[i] = tvals[offset+i] != 0
+		}
+		retVal = append(retVal, fn(p, t, h, w, opts...))
+	}
+
+	return retVal
+}
+
+// perClassSurfaceMetric applies fn to every class of every h x w plane of
+// pred and target of class indices.
+func perClassSurfaceMetric(fn surfaceMetric, pred, target *ts.Tensor, nclasses int64, opts []MetricOption) [][]float64 {
+	pvals, h, w := planeValues(pred)
+	tvals, _, _ := planeValues(target)
+
+	var retVal [][]float64
+	p := make([]bool, h*w)
+	t := make([]bool, h*w)
+	for offset := 0; offset < len(pvals); offset += h * w {
+		scores := make([]float64, nclasses)
+		for c := range scores {
+			for i := range p {
+				p[i] = int64(pvals[offset+i]) == int64(c)
+				t[i] = int64(tvals[offset+i]) == int64(c)
+			}
+			scores[c] = fn(p, t, h, w, opts...)
+		}
+		retVal = append(retVal, scores)
+	}
+
+	return retVal
+}
+
+// HausdorffDistance95 computes HD95 (see HD95) of every sample of binary
+// prediction and target of shape [B, H, W] (or [H, W]). Nonzero values are foreground.
+// It returns a score per sample.
+func HausdorffDistance95(pred, target *ts.Tensor, opts ...MetricOption) []float64 {
+	return binarySurfaceMetric(HD95, pred, target, opts)
+}
+
+// AverageSurfaceDistance computes ASSD (see ASSD) of every sample of binary
+// prediction and target of shape [B, H, W] (or [H, W]). Nonzero values are foreground.
+// It returns a score per sample.
+func AverageSurfaceDistance(pred, target *ts.Tensor, opts ...MetricOption) []float64 {
+	return binarySurfaceMetric(ASSD, pred, target, opts)
+}
+
+// HausdorffDistance95PerClass computes HD95 (see HD95) of every class of every
+// sample of prediction and target of class indices of shape [B, H, W].
+// It returns scores of shape [B][nclasses].
+func HausdorffDistance95PerClass(pred, target *ts.Tensor, nclasses int64, opts ...MetricOption) [][]float64 {
+	return perClassSurfaceMetric(HD95, pred, target, nclasses, opts)
+}
+
+// AverageSurfaceDistancePerClass computes ASSD (see ASSD) of every class of
+// every sample of prediction and target of class indices of shape [B, H, W].
+// It returns scores of shape [B][nclasses].
+func AverageSurfaceDistancePerClass(pred, target *ts.Tensor, nclasses int64, opts ...MetricOption) [][]float64 {
+	return perClassSurfaceMetric(ASSD, pred, target, nclasses, opts)
+}
@@ -0,0 +1,116 @@
+package metric_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func TestHD95(t *testing.T) {
+	// 4x3 images with single foreground pixels 3 rows apart.
+	pred := []bool{
+		true, false, false,
+		false, false, false,
+		false, false, false,
+		false, false, false,
+	}
+	target := []bool{
+		false, false, false,
+		false, false, false,
+		false, false, false,
+		true, false, false,
+	}
+	empty := make([]bool, 12)
+
+	tests := []struct {
+		name    string
+		pred    []bool
+		target  []bool
+		spacing []float64
+		want    float64
+	}{
+		{"identical", pred, pred, nil, 0},
+		{"3 pixels apart", pred, target, nil, 3},
+		{"anisotropic spacing", pred, target, []float64{2.0, 1.0}, 6},
+		{"both empty", empty, empty, nil, 0},
+		{"empty prediction", empty, target, nil, math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		got := metric.HD95(tt.pred, tt.target, 4, 3, metric.WithSpacing(tt.spacing...))
+		if got != tt.want {
+			t.Errorf("%v - Want: %v\n", tt.name, tt.want)
+			t.Errorf("%v - Got: %v\n", tt.name, got)
+		}
+		got = metric.ASSD(tt.pred, tt.target, 4, 3, metric.WithSpacing(tt.spacing...))
+		if got != tt.want {
+			t.Errorf("%v - Want ASSD: %v\n", tt.name, tt.want)
+			t.Errorf("%v - Got ASSD: %v\n", tt.name, got)
+		}
+	}
+}
+
+func TestHD95_EmptyPolicy(t *testing.T) {
+	empty := make([]bool, 4)
+	if got := metric.HD95(empty, empty, 2, 2, metric.WithEmptyPolicy(metric.EmptyNaN)); !math.IsNaN(got) {
+		t.Errorf("Want NaN HD95 of empty masks. Got: %v\n", got)
+	}
+	if got := metric.ASSD(empty, empty, 2, 2, metric.WithEmptyPolicy(metric.EmptySkip)); !math.IsNaN(got) {
+		t.Errorf("Want NaN ASSD of skipped empty masks. Got: %v\n", got)
+	}
+}
+
+func TestHD95_Directed(t *testing.T) {
+	// 1x20 images: target of 10 pixels and prediction of its first pixel.
+	// Directed distances are [0] and [0, 1, ..., 9].
+	target := make([]bool, 20)
+	for i := 0; i < 10; i++ {
+		target[i] = true
+	}
+	pred := make([]bool, 20)
+	pred[0] = true
+
+	// NOTE. 95th percentile of pooled distances would be 8.5.
+	want := 8.55
+	got := metric.HD95(pred, target, 1, 20)
+	if math.Abs(want-got) > 1e-9 {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestSurface(t *testing.T) {
+	fg := []bool{
+		true, true, true, false,
+		true, true, true, false,
+		true, true, true, false,
+	}
+
+	got := metric.Surface(fg, 3, 4)
+	// Only the center pixel is interior.
+	if got[5] || !got[0] || !got[6] || got[3] {
+		t.Errorf("Unexpected surface. Got: %v\n", got)
+	}
+}
+
+func TestHausdorffDistance95PerClass(t *testing.T) {
+	pred := ts.MustOfSlice([]int64{
+		0, 1, 1,
+		0, 0, 0,
+	}).MustView([]int64{1, 2, 3}, true)
+	target := ts.MustOfSlice([]int64{
+		0, 0, 1,
+		0, 0, 2,
+	}).MustView([]int64{1, 2, 3}, true)
+
+	got := metric.HausdorffDistance95PerClass(pred, target, 3)
+	if len(got) != 1 || len(got[0]) != 3 {
+		t.Errorf("Want scores of shape [1][3]. Got: %v\n", got)
+	}
+	if !math.IsInf(got[0][2], 1) {
+		t.Errorf("Want +Inf for class missing from prediction. Got: %v\n", got[0][2])
+	}
+}
@@ -0,0 +1,220 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// ThresholdSweep accumulates binary probabilities and masks over batches
+// (i.e. a whole validation set) to evaluate scores at many thresholds.
+//
+// NOTE. To keep memory constant, probabilities are binned into a histogram
+// of positive and negative pixels instead of being stored.
+type ThresholdSweep struct {
+	nbins int64
+	pos   []int64 // number of target positive pixels per probability bin
+	neg   []int64 // number of target negative pixels per probability bin
+}
+
+// NewThresholdSweep creates a new ThresholdSweep.
+//
+// nbins: Optional (default=1000). Number of probability bins, hence resolution of thresholds.
+func NewThresholdSweep(nbinsOpt ...int64) *ThresholdSweep {
+	var nbins int64 = 1000
+	if len(nbinsOpt) > 0 {
+		nbins = nbinsOpt[0]
+	}
+
+	return &ThresholdSweep{
+		nbins: nbins,
+		pos:   make([]int64, nbins),
+		neg:   make([]int64, nbins),
+	}
+}
+
+// Update adds a batch of probabilities (i.e. after sigmoid) and binary masks
+// of the same shape.
+func (s *ThresholdSweep) Update(prob, mask *ts.Tensor) {
+	idx := prob.MustTotype(gotch.Double, false).MustReshape([]int64{-1}, true).MustMulScalar(ts.FloatScalar(float64(s.nbins)), true)
+	idx = idx.MustFloor(true).MustClamp(ts.FloatScalar(0), ts.FloatScalar(float64(s.nbins-1)), true).MustTotype(gotch.Int64, true)
+
+	m := mask.MustReshape([]int64{-1}, false).MustGt(ts.FloatScalar(0.5), true)
+	notM := m.MustLogicalNot(false)
+
+	posCounts := idx.MustMaskedSelect(m, false).MustBincount(ts.NewTensor(), s.nbins, true).MustTo(gotch.CPU, true)
+	negCounts := idx.MustMaskedSelect(notM, true).MustBincount(ts.NewTensor(), s.nbins, true).MustTo(gotch.CPU, true)
+	m.MustDrop()
+	notM.MustDrop()
+
+	for i, v := range posCounts.Int64Values() {
+		s.pos[i] += v
+	}
+	for i, v := range negCounts.Int64Values() {
+		s.neg[i] += v
+	}
+	posCounts.MustDrop()
+	negCounts.MustDrop()
+}
+
+// Reset clears all accumulated counts.
+func (s *ThresholdSweep) Reset() {
+	for i := range s.pos {
+		s.pos[i] = 0
+		s.neg[i] = 0
+	}
+}
+
+// ThresholdCurve holds scores at each threshold. Scores are computed from
+// pixel counts over all accumulated samples.
+type ThresholdCurve struct {
+	Thresholds []float64
+	Dice       []float64
+	IoU        []float64
+	Precision  []float64
+	Recall     []float64
+}
+
+// Curve computes scores at thresholds i/nbins for i = 0, 1, ..., nbins-1.
+// A pixel is predicted positive if its probability >= threshold. Precision is
+// NaN where nothing is predicted positive. Dice and IoU where both prediction
+// and target are empty are scored by empty policy (default=EmptySkip, i.e.
+// NaN). See MetricOptions.
+func (s *ThresholdSweep) Curve(opts ...MetricOption) *ThresholdCurve {
+	o := newMetricOptions(EmptySkip, opts)
+	n := int(s.nbins)
+	c := &ThresholdCurve{
+		Thresholds: make([]float64, n),
+		Dice:       make([]float64, n),
+		IoU:        make([]float64, n),
+		Precision:  make([]float64, n),
+		Recall:     make([]float64, n),
+	}
+
+	var totalPos float64
+	for _, v := range s.pos {
+		totalPos += float64(v)
+	}
+
+	// Sweep from highest threshold down, accumulating predicted positives.
+	var tp, fp float64
+	for i := n - 1; i >= 0; i-- {
+		tp += float64(s.pos[i])
+		fp += float64(s.neg[i])
+		fn := totalPos - tp
+
+		c.Thresholds[i] = float64(i) / float64(n)
+		c.Dice[i], _ = o.score(2*tp, 2*tp+fp+fn)
+		c.IoU[i], _ = o.score(tp, tp+fp+fn)
+		c.Precision[i] = ratio(tp, tp+fp)
+		c.Recall[i] = ratio(tp, totalPos)
+	}
+
+	return c
+}
+
+// OptimalThreshold returns threshold maximizing Dice (hence IoU) and its Dice score.
+func (c *ThresholdCurve) OptimalThreshold() (threshold, dice float64) {
+	best := -1
+	for i, d := range c.Dice {
+		if math.IsNaN(d) {
+			continue
+		}
+		if best < 0 || d > c.Dice[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0.5, math.NaN()
+	}
+
+	return c.Thresholds[best], c.Dice[best]
+}
+
+// PRAUC returns area under precision-recall curve computed as average
+// precision: sum of precisions weighted by recall increments.
+// It returns NaN if there is no positive target pixel.
+// Ref. https://scikit-learn.org/stable/modules/generated/sklearn.metrics.average_precision_score.html
+func (c *ThresholdCurve) PRAUC() float64 {
+	var (
+		auc        float64
+		prevRecall float64
+	)
+	for i := len(c.Thresholds) - 1; i >= 0; i-- {
+		r, p := c.Recall[i], c.Precision[i]
+		if math.IsNaN(r) { // no positive target
+			return r
+		}
+		if math.IsNaN(p) { // nothing predicted positive yet
+			continue
+		}
+		auc += (r - prevRecall) * p
+		prevRecall = r
+	}
+
+	return auc
+}
+
+func curveXYs(x, y []float64) plotter.XYs {
+	var xys plotter.XYs
+	for i := range x {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		xys = append(xys, plotter.XY{X: x[i], Y: y[i]})
+	}
+
+	return xys
+}
+
+// Plot saves a plot of Dice, IoU, precision and recall against threshold to file.
+// Image format is inferred from file extension (e.g. .png, .svg, .pdf).
+func (c *ThresholdCurve) Plot(file string) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = "Threshold sweep"
+	p.X.Label.Text = "Threshold"
+	p.Y.Label.Text = "Score"
+	p.X.Min, p.X.Max = 0, 1
+	p.Y.Min, p.Y.Max = 0, 1
+
+	err = plotutil.AddLines(p,
+		"Dice", curveXYs(c.Thresholds, c.Dice),
+		"IoU", curveXYs(c.Thresholds, c.IoU),
+		"Precision", curveXYs(c.Thresholds, c.Precision),
+		"Recall", curveXYs(c.Thresholds, c.Recall),
+	)
+	if err != nil {
+		return err
+	}
+
+	return p.Save(6*vg.Inch, 4*vg.Inch, file)
+}
+
+// PlotPR saves a precision-recall curve plot to file.
+// Image format is inferred from file extension (e.g. .png, .svg, .pdf).
+func (c *ThresholdCurve) PlotPR(file string) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = "Precision-Recall"
+	p.X.Label.Text = "Recall"
+	p.Y.Label.Text = "Precision"
+	p.X.Min, p.X.Max = 0, 1
+	p.Y.Min, p.Y.Max = 0, 1
+
+	err = plotutil.AddLines(p, "PR", curveXYs(c.Recall, c.Precision))
+	if err != nil {
+		return err
+	}
+
+	return p.Save(5*vg.Inch, 5*vg.Inch, file)
+}
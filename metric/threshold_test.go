@@ -0,0 +1,89 @@
+package metric_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/metric"
+)
+
+func newTestSweep() *metric.ThresholdSweep {
+	s := metric.NewThresholdSweep(10)
+	prob := ts.MustOfSlice([]float64{0.1, 0.4, 0.6, 0.9}).MustView([]int64{1, 2, 2}, true)
+	mask := ts.MustOfSlice([]float64{0, 1, 0, 1}).MustView([]int64{1, 2, 2}, true)
+	s.Update(prob, mask)
+
+	return s
+}
+
+func TestThresholdSweep_EmptyPolicy(t *testing.T) {
+	s := metric.NewThresholdSweep(10)
+	prob := ts.MustOfSlice([]float64{0.1, 0.1, 0.1, 0.1}).MustView([]int64{1, 2, 2}, true)
+	mask := ts.MustOfSlice([]float64{0, 0, 0, 0}).MustView([]int64{1, 2, 2}, true)
+	s.Update(prob, mask)
+
+	// threshold 0.5: nothing predicted and no target
+	if c := s.Curve(); !math.IsNaN(c.Dice[5]) || !math.IsNaN(c.IoU[5]) {
+		t.Errorf("Want NaN Dice and IoU by default. Got: %v, %v\n", c.Dice[5], c.IoU[5])
+	}
+	c := s.Curve(metric.WithEmptyPolicy(metric.EmptyScoreOne))
+	assertFloats(t, "Dice@0.5", []float64{1, 1}, []float64{c.Dice[5], c.IoU[5]})
+	assertFloats(t, "Dice@0.0", []float64{0}, []float64{c.Dice[0]})
+}
+
+func TestThresholdSweep_Curve(t *testing.T) {
+	c := newTestSweep().Curve()
+
+	// threshold 0.5: 1 TP, 1 FP, 1 FN
+	assertFloats(t, "Dice@0.5", []float64{0.5}, []float64{c.Dice[5]})
+	assertFloats(t, "Precision@0.5", []float64{0.5}, []float64{c.Precision[5]})
+	assertFloats(t, "Recall@0.5", []float64{0.5}, []float64{c.Recall[5]})
+
+	// threshold 0.3: 2 TP, 1 FP, 0 FN
+	assertFloats(t, "Dice@0.3", []float64{0.8}, []float64{c.Dice[3]})
+	assertFloats(t, "IoU@0.3", []float64{2.0 / 3}, []float64{c.IoU[3]})
+
+	threshold, dice := c.OptimalThreshold()
+	assertFloats(t, "OptimalThreshold", []float64{0.2, 0.8}, []float64{threshold, dice})
+
+	// AP = 0.5 * 1 + 0.5 * 2/3
+	assertFloats(t, "PRAUC", []float64{5.0 / 6}, []float64{c.PRAUC()})
+}
+
+func TestThresholdSweep_Reset(t *testing.T) {
+	s := newTestSweep()
+	s.Reset()
+
+	if !math.IsNaN(s.Curve().PRAUC()) {
+		t.Errorf("Want NaN PR-AUC without positive pixels.")
+	}
+}
+
+func TestThresholdCurve_Plot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "threshold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newTestSweep().Curve()
+	for _, f := range []string{"sweep.png", "pr.svg"} {
+		file := filepath.Join(dir, f)
+		if f == "sweep.png" {
+			err = c.Plot(file)
+		} else {
+			err = c.PlotPR(file)
+		}
+		if err != nil {
+			t.Errorf("Unexpected error. Got: %v\n", err)
+		}
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Expected plot file %v. Got: %v\n", file, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package transform
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// ElasticTransform deforms image and masks with a random smooth displacement
+// field: per-pixel displacements uniform in [-1, 1] are smoothed with a
+// Gaussian of standard deviation Sigma and scaled by Alpha (both in pixels).
+// Ref. Simard et al., "Best Practices for Convolutional Neural Networks
+// Applied to Visual Document Analysis", 2003.
+type ElasticTransform struct {
+	Alpha float64 // displacement scale
+	Sigma float64 // smoothness of displacement field
+	Options
+}
+
+// NewElasticTransform creates a new ElasticTransform, e.g. NewElasticTransform(34, 4).
+func NewElasticTransform(alpha, sigma float64, options ...Option) *ElasticTransform {
+	return &ElasticTransform{
+		Alpha:   alpha,
+		Sigma:   sigma,
+		Options: NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *ElasticTransform) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	h, w := imageSize(s)
+	n := int(h * w)
+	var kernel []float64
+	if t.Sigma > 0 {
+		kernel = gaussianKernel(t.Sigma)
+	}
+	field := func() []float64 {
+		d := make([]float64, n)
+		for i := range d {
+			d[i] = 2*rng.Float64() - 1
+		}
+		if kernel != nil {
+			convolvePlane(d, int(h), int(w), kernel)
+		}
+		for i := range d {
+			d[i] *= t.Alpha
+		}
+		return d
+	}
+	dx, dy := field(), field()
+
+	return warp(s, h, w, t.Options, func(x, y float64) (float64, float64) {
+		i := int(y)*int(w) + int(x)
+		return x + dx[i], y + dy[i]
+	})
+}
+
+// GridDistortion splits image into NumSteps x NumSteps cells and randomly
+// stretches or squeezes each row and column of cells by a factor in
+// [1 - DistortLimit, 1 + DistortLimit]. Image borders are kept.
+type GridDistortion struct {
+	NumSteps     int
+	DistortLimit float64
+	Options
+}
+
+// NewGridDistortion creates a new GridDistortion, e.g. NewGridDistortion(5, 0.3).
+func NewGridDistortion(numSteps int, distortLimit float64, options ...Option) *GridDistortion {
+	return &GridDistortion{
+		NumSteps:     numSteps,
+		DistortLimit: distortLimit,
+		Options:      NewOptions(options...),
+	}
+}
+
+// gridLines returns positions in input of numSteps+1 grid lines of an axis
+// of given size with randomly distorted cells.
+func (t *GridDistortion) gridLines(size int64, rng *rand.Rand) []float64 {
+	lines := make([]float64, t.NumSteps+1)
+	for i := 1; i <= t.NumSteps; i++ {
+		lines[i] = lines[i-1] + 1 + uniform([2]float64{-t.DistortLimit, t.DistortLimit}, rng)
+	}
+	// Normalize to cover the whole axis.
+	scale := float64(size) / lines[t.NumSteps]
+	for i := range lines {
+		lines[i] *= scale
+	}
+
+	return lines
+}
+
+// piecewise maps pixel coordinate p of an axis of given size from uniform
+// grid lines to distorted grid lines.
+func piecewise(p float64, size int64, lines []float64) float64 {
+	steps := len(lines) - 1
+	cell := float64(size) / float64(steps)
+	u := p + 0.5 // pixel center to continuous coordinate
+	i := int(u / cell)
+	if i >= steps {
+		i = steps - 1
+	}
+	f := (u - float64(i)*cell) / cell
+
+	return lines[i] + f*(lines[i+1]-lines[i]) - 0.5
+}
+
+// Apply implements Transform interface.
+func (t *GridDistortion) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) || t.NumSteps < 1 {
+		return cloneSample(s)
+	}
+
+	h, w := imageSize(s)
+	xs := t.gridLines(w, rng)
+	ys := t.gridLines(h, rng)
+
+	return warp(s, h, w, t.Options, func(x, y float64) (float64, float64) {
+		return piecewise(x, w, xs), piecewise(y, h, ys)
+	})
+}
+
+// OpticalDistortion applies random radial (barrel or pincushion) lens
+// distortion r' = r * (1 + k * r^2) around a randomly shifted center, with
+// k in [-DistortLimit, DistortLimit] and radius normalized by half of the
+// image diagonal. Center shift is in [-ShiftLimit, ShiftLimit] as fraction of
+// width and height.
+type OpticalDistortion struct {
+	DistortLimit float64
+	ShiftLimit   float64
+	Options
+}
+
+// NewOpticalDistortion creates a new OpticalDistortion, e.g. NewOpticalDistortion(0.3, 0.05).
+func NewOpticalDistortion(distortLimit, shiftLimit float64, options ...Option) *OpticalDistortion {
+	return &OpticalDistortion{
+		DistortLimit: distortLimit,
+		ShiftLimit:   shiftLimit,
+		Options:      NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *OpticalDistortion) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	h, w := imageSize(s)
+	k := uniform([2]float64{-t.DistortLimit, t.DistortLimit}, rng)
+	cx := float64(w-1)/2 + uniform([2]float64{-t.ShiftLimit, t.ShiftLimit}, rng)*float64(w)
+	cy := float64(h-1)/2 + uniform([2]float64{-t.ShiftLimit, t.ShiftLimit}, rng)*float64(h)
+	norm := math.Hypot(float64(w), float64(h)) / 2
+
+	return warp(s, h, w, t.Options, func(x, y float64) (float64, float64) {
+		dx, dy := (x-cx)/norm, (y-cy)/norm
+		f := 1 + k*(dx*dx+dy*dy)
+		return cx + dx*f*norm, cy + dy*f*norm
+	})
+}
@@ -0,0 +1,84 @@
+package transform_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/iseg/transform"
+)
+
+func TestDistortion(t *testing.T) {
+	// Fill image and mask with the same value to compare them outside input too.
+	opts := []transform.Option{
+		transform.WithP(1),
+		transform.WithInterpolation(transform.Nearest),
+		transform.WithFill(255),
+		transform.WithMaskFill(255),
+	}
+	tests := []struct {
+		name     string
+		tf       transform.Transform
+		identity transform.Transform
+	}{
+		{
+			"ElasticTransform",
+			transform.NewElasticTransform(8, 2, opts...),
+			transform.NewElasticTransform(0, 2, opts...),
+		},
+		{
+			"GridDistortion",
+			transform.NewGridDistortion(3, 0.5, opts...),
+			transform.NewGridDistortion(3, 0, opts...),
+		},
+		{
+			"OpticalDistortion",
+			transform.NewOpticalDistortion(0.8, 0.1, opts...),
+			transform.NewOpticalDistortion(0, 0, opts...),
+		},
+	}
+
+	s := newSample(12, 15, seq(180))
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		out := tt.identity.Apply(s, rng)
+		assertMask(t, seq(180), out)
+
+		for i := 0; i < 3; i++ {
+			out = tt.tf.Apply(s, rng)
+			assertJoint(t, out)
+			if !reflect.DeepEqual([]int64{12, 15}, out.Mask.MustSize()) {
+				t.Errorf("%v - Want: %v\n", tt.name, []int64{12, 15})
+				t.Errorf("%v - Got: %v\n", tt.name, out.Mask.MustSize())
+			}
+			if reflect.DeepEqual(seq(180), out.Mask.Int64Values()) {
+				t.Errorf("%v - Expected distorted mask.", tt.name)
+			}
+		}
+	}
+}
+
+func TestGridDistortion_Borders(t *testing.T) {
+	s := newSample(10, 10, seq(100))
+	tf := transform.NewGridDistortion(4, 0.5, transform.WithP(1))
+	out := tf.Apply(s, rand.New(rand.NewSource(1)))
+
+	// Corner pixels stay in place.
+	mask := out.Mask.Int64Values()
+	for _, i := range []int{0, 9, 90, 99} {
+		if mask[i] != int64(i) {
+			t.Errorf("Want: %v\n", i)
+			t.Errorf("Got: %v\n", mask[i])
+		}
+	}
+}
+
+func TestElasticTransform_Reproducible(t *testing.T) {
+	s := newSample(8, 8, seq(64))
+	tf := transform.NewElasticTransform(5, 1, transform.WithP(1))
+
+	want := tf.Apply(s, rand.New(rand.NewSource(5)))
+	got := tf.Apply(s, rand.New(rand.NewSource(5)))
+	assertMask(t, want.Mask.Int64Values(), got)
+	assertClose(t, want.Image.Float64Values(), got.Image.Float64Values(), 0)
+}
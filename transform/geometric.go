@@ -0,0 +1,334 @@
+package transform
+
+import (
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// imageSize returns height and width of image tensor of shape [C, H, W].
+func imageSize(s *dutil.SegmentationSample) (h, w int64) {
+	size := s.Image.MustSize()
+	if len(size) != 3 {
+		log.Fatalf("Expected image of shape [C, H, W]. Got: %v\n", size)
+	}
+
+	return size[1], size[2]
+}
+
+// samplingGrid builds a grid of shape [1, outH, outW, 2] for grid sampler
+// from fn that maps output pixel (x, y) to input pixel (sx, sy) of image of
+// size h x w. Pixel coordinates are pixel centers, i.e. 0, 1, ..., w-1.
+func samplingGrid(h, w, outH, outW int64, device gotch.Device, fn func(x, y float64) (sx, sy float64)) *ts.Tensor {
+	grid := make([]float32, outH*outW*2)
+	for y := int64(0); y < outH; y++ {
+		for x := int64(0); x < outW; x++ {
+			sx, sy := fn(float64(x), float64(y))
+			i := (y*outW + x) * 2
+			// Normalized coordinates with align corners = false.
+			grid[i] = float32((2*sx+1)/float64(w) - 1)
+			grid[i+1] = float32((2*sy+1)/float64(h) - 1)
+		}
+	}
+
+	return ts.MustOfSlice(grid).MustView([]int64{1, outH, outW, 2}, true).MustTo(device, true)
+}
+
+// gridSample resamples tensor of shape [C, H, W] or [H, W] with grid.
+// Pixels sampled outside input get fill value.
+func gridSample(x, grid *ts.Tensor, mode Interpolation, fill float64) *ts.Tensor {
+	dtype := x.DType()
+	dim := x.Dim()
+
+	in := x.MustTotype(gotch.Float, false)
+	if dim == 2 {
+		in = in.MustUnsqueeze(0, true)
+	}
+	in = in.MustUnsqueeze(0, true)
+	if fill != 0 {
+		in = in.MustSubScalar(ts.FloatScalar(fill), true)
+	}
+
+	// zero padding
+	out := ts.MustGridSampler(in, grid, int64(mode), 0, false)
+	in.MustDrop()
+	if fill != 0 {
+		out = out.MustAddScalar(ts.FloatScalar(fill), true)
+	}
+
+	out = out.MustSqueezeDim(0, true)
+	if dim == 2 {
+		out = out.MustSqueezeDim(0, true)
+	}
+
+	return out.MustTotype(dtype, true)
+}
+
+// warp resamples image and masks of sample to size outH x outW with fn
+// mapping output pixels to input pixels (see samplingGrid).
+func warp(s *dutil.SegmentationSample, outH, outW int64, opts Options, fn func(x, y float64) (sx, sy float64)) *dutil.SegmentationSample {
+	h, w := imageSize(s)
+	grid := samplingGrid(h, w, outH, outW, s.Image.MustDevice(), fn)
+	defer grid.MustDrop()
+
+	out := &dutil.SegmentationSample{
+		Name:  s.Name,
+		Image: gridSample(s.Image, grid, opts.Interpolation, opts.Fill),
+	}
+	if s.Mask != nil {
+		out.Mask = gridSample(s.Mask, grid, Nearest, float64(opts.MaskFill))
+	}
+	if s.Instance != nil {
+		out.Instance = gridSample(s.Instance, grid, Nearest, 0)
+	}
+
+	return out
+}
+
+// HFlip flips image and masks horizontally.
+type HFlip struct {
+	Options
+}
+
+func NewHFlip(options ...Option) *HFlip {
+	return &HFlip{NewOptions(options...)}
+}
+
+// Apply implements Transform interface.
+func (t *HFlip) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	return mapSample(s, func(x *ts.Tensor, _ bool) *ts.Tensor {
+		return x.MustFlip([]int64{-1}, false)
+	})
+}
+
+// VFlip flips image and masks vertically.
+type VFlip struct {
+	Options
+}
+
+func NewVFlip(options ...Option) *VFlip {
+	return &VFlip{NewOptions(options...)}
+}
+
+// Apply implements Transform interface.
+func (t *VFlip) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	return mapSample(s, func(x *ts.Tensor, _ bool) *ts.Tensor {
+		return x.MustFlip([]int64{-2}, false)
+	})
+}
+
+// RandomRotate90 rotates image and masks counterclockwise by a random
+// multiple of 90 degrees (0, 90, 180 or 270). Height and width are swapped
+// for odd multiples.
+type RandomRotate90 struct {
+	Options
+}
+
+func NewRandomRotate90(options ...Option) *RandomRotate90 {
+	return &RandomRotate90{NewOptions(options...)}
+}
+
+// Apply implements Transform interface.
+func (t *RandomRotate90) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	k := int64(rng.Intn(4))
+	return mapSample(s, func(x *ts.Tensor, _ bool) *ts.Tensor {
+		return x.MustRot90(k, []int64{-2, -1}, false)
+	})
+}
+
+// Affine applies a random affine transformation of scaling, rotation around
+// image center and translation. Output has the same size as input.
+type Affine struct {
+	Scale     [2]float64 // range of scale factor
+	Degrees   [2]float64 // range of counterclockwise rotation angle in degrees
+	Translate [2]float64 // maximum absolute shift as fraction of width and height
+	Options
+}
+
+// NewAffine creates a new Affine transform.
+//
+// E.g. NewAffine([2]float64{0.9, 1.1}, [2]float64{-15, 15}, [2]float64{0.1, 0.1})
+func NewAffine(scale, degrees, translate [2]float64, options ...Option) *Affine {
+	return &Affine{
+		Scale:     scale,
+		Degrees:   degrees,
+		Translate: translate,
+		Options:   NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *Affine) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	h, w := imageSize(s)
+	scale := uniform(t.Scale, rng)
+	angle := uniform(t.Degrees, rng) * math.Pi / 180
+	tx := uniform([2]float64{-t.Translate[0], t.Translate[0]}, rng) * float64(w)
+	ty := uniform([2]float64{-t.Translate[1], t.Translate[1]}, rng) * float64(h)
+
+	// Inverse mapping of output to input pixels. Y axis points down so
+	// counterclockwise rotation of image is clockwise in pixel coordinates.
+	cx, cy := float64(w-1)/2, float64(h-1)/2
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	return warp(s, h, w, t.Options, func(x, y float64) (float64, float64) {
+		dx, dy := x-cx-tx, y-cy-ty
+		sx := (cos*dx - sin*dy) / scale
+		sy := (sin*dx + cos*dy) / scale
+		return sx + cx, sy + cy
+	})
+}
+
+// RandomResizedCrop crops a random region of random area and aspect ratio
+// and resizes it to Height x Width.
+// Ref. https://pytorch.org/vision/stable/transforms.html#torchvision.transforms.RandomResizedCrop
+type RandomResizedCrop struct {
+	Height int64
+	Width  int64
+	Scale  [2]float64 // range of crop area as fraction of image area
+	Ratio  [2]float64 // range of crop aspect ratio (width / height)
+	Options
+}
+
+// NewRandomResizedCrop creates a new RandomResizedCrop with default area
+// scale [0.08, 1] and aspect ratio [3/4, 4/3]. It's applied with probability
+// 1 unless WithP is given. If skipped, the whole image is resized.
+func NewRandomResizedCrop(height, width int64, options ...Option) *RandomResizedCrop {
+	options = append([]Option{WithP(1)}, options...)
+	return &RandomResizedCrop{
+		Height:  height,
+		Width:   width,
+		Scale:   [2]float64{0.08, 1},
+		Ratio:   [2]float64{3.0 / 4, 4.0 / 3},
+		Options: NewOptions(options...),
+	}
+}
+
+// cropParams returns top, left, height and width of a random crop.
+func (t *RandomResizedCrop) cropParams(h, w int64, rng *rand.Rand) (top, left, ch, cw int64) {
+	area := float64(h * w)
+	logRatio := [2]float64{math.Log(t.Ratio[0]), math.Log(t.Ratio[1])}
+	for i := 0; i < 10; i++ {
+		targetArea := area * uniform(t.Scale, rng)
+		ratio := math.Exp(uniform(logRatio, rng))
+		cw = int64(math.Round(math.Sqrt(targetArea * ratio)))
+		ch = int64(math.Round(math.Sqrt(targetArea / ratio)))
+		if cw > 0 && cw <= w && ch > 0 && ch <= h {
+			top = int64(rng.Intn(int(h - ch + 1)))
+			left = int64(rng.Intn(int(w - cw + 1)))
+			return top, left, ch, cw
+		}
+	}
+
+	// Fallback to central crop
+	ratio := float64(w) / float64(h)
+	switch {
+	case ratio < t.Ratio[0]:
+		cw = w
+		ch = int64(math.Round(float64(w) / t.Ratio[0]))
+	case ratio > t.Ratio[1]:
+		ch = h
+		cw = int64(math.Round(float64(h) * t.Ratio[1]))
+	default:
+		ch, cw = h, w
+	}
+
+	return (h - ch) / 2, (w - cw) / 2, ch, cw
+}
+
+// Apply implements Transform interface.
+func (t *RandomResizedCrop) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	h, w := imageSize(s)
+	top, left, ch, cw := int64(0), int64(0), h, w
+	if !skip(t.P, rng) {
+		top, left, ch, cw = t.cropParams(h, w, rng)
+	}
+
+	scaleX := float64(cw) / float64(t.Width)
+	scaleY := float64(ch) / float64(t.Height)
+	return warp(s, t.Height, t.Width, t.Options, func(x, y float64) (float64, float64) {
+		return float64(left) + (x+0.5)*scaleX - 0.5, float64(top) + (y+0.5)*scaleY - 0.5
+	})
+}
+
+// PadIfNeeded pads image and masks evenly on both sides to at least
+// MinHeight x MinWidth. Image is padded with Fill and masks with MaskFill.
+type PadIfNeeded struct {
+	MinHeight int64
+	MinWidth  int64
+	Options
+}
+
+// NewPadIfNeeded creates a new PadIfNeeded. It's applied with probability 1
+// unless WithP is given.
+func NewPadIfNeeded(minHeight, minWidth int64, options ...Option) *PadIfNeeded {
+	options = append([]Option{WithP(1)}, options...)
+	return &PadIfNeeded{
+		MinHeight: minHeight,
+		MinWidth:  minWidth,
+		Options:   NewOptions(options...),
+	}
+}
+
+// padTensor pads last 2 dimensions of x with constant value.
+func padTensor(x *ts.Tensor, pad []int64, value float64) *ts.Tensor {
+	if value == 0 {
+		return x.MustConstantPadNd(pad, false)
+	}
+
+	dtype := x.DType()
+	out := x.MustTotype(gotch.Double, false).MustSubScalar(ts.FloatScalar(value), true)
+	out = out.MustConstantPadNd(pad, true).MustAddScalar(ts.FloatScalar(value), true)
+
+	return out.MustTotype(dtype, true)
+}
+
+// Apply implements Transform interface.
+func (t *PadIfNeeded) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	h, w := imageSize(s)
+	if (h >= t.MinHeight && w >= t.MinWidth) || skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	var padH, padW int64
+	if h < t.MinHeight {
+		padH = t.MinHeight - h
+	}
+	if w < t.MinWidth {
+		padW = t.MinWidth - w
+	}
+	// left, right, top, bottom
+	pad := []int64{padW / 2, padW - padW/2, padH / 2, padH - padH/2}
+
+	out := &dutil.SegmentationSample{
+		Name:  s.Name,
+		Image: padTensor(s.Image, pad, t.Fill),
+	}
+	if s.Mask != nil {
+		out.Mask = padTensor(s.Mask, pad, float64(t.MaskFill))
+	}
+	if s.Instance != nil {
+		out.Instance = padTensor(s.Instance, pad, 0)
+	}
+
+	return out
+}
@@ -0,0 +1,201 @@
+package transform_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+	"github.com/sugarme/iseg/transform"
+)
+
+// newSample creates a sample of size h x w whose single-channel image and
+// mask both hold vals so that joint transforms can be checked by comparing them.
+func newSample(h, w int64, vals []int64) *dutil.SegmentationSample {
+	imgVals := make([]float32, len(vals))
+	for i, v := range vals {
+		imgVals[i] = float32(v)
+	}
+
+	return &dutil.SegmentationSample{
+		Name:  "sample",
+		Image: ts.MustOfSlice(imgVals).MustView([]int64{1, h, w}, true),
+		Mask:  ts.MustOfSlice(vals).MustView([]int64{h, w}, true),
+	}
+}
+
+func seq(n int64) []int64 {
+	vals := make([]int64, n)
+	for i := range vals {
+		vals[i] = int64(i)
+	}
+
+	return vals
+}
+
+// imageInt64s returns image values rounded to integers.
+func imageInt64s(s *dutil.SegmentationSample) []int64 {
+	vals := s.Image.Float64Values()
+	out := make([]int64, len(vals))
+	for i, v := range vals {
+		out[i] = int64(math.Round(v))
+	}
+
+	return out
+}
+
+func assertMask(t *testing.T, want []int64, s *dutil.SegmentationSample) {
+	t.Helper()
+	if got := s.Mask.Int64Values(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+// assertJoint checks that image and mask were transformed identically.
+func assertJoint(t *testing.T, s *dutil.SegmentationSample) {
+	t.Helper()
+	img, mask := imageInt64s(s), s.Mask.Int64Values()
+	if !reflect.DeepEqual(img, mask) {
+		t.Errorf("Want image: %v\n", mask)
+		t.Errorf("Got image: %v\n", img)
+	}
+}
+
+func TestFlip(t *testing.T) {
+	s := newSample(2, 3, seq(6))
+	rng := rand.New(rand.NewSource(1))
+
+	out := transform.NewHFlip(transform.WithP(1)).Apply(s, rng)
+	assertMask(t, []int64{2, 1, 0, 5, 4, 3}, out)
+	assertJoint(t, out)
+
+	out = transform.NewVFlip(transform.WithP(1)).Apply(s, rng)
+	assertMask(t, []int64{3, 4, 5, 0, 1, 2}, out)
+	assertJoint(t, out)
+
+	out = transform.NewHFlip(transform.WithP(0)).Apply(s, rng)
+	assertMask(t, seq(6), out)
+}
+
+func TestRandomRotate90(t *testing.T) {
+	s := newSample(2, 3, seq(6))
+	rotations := [][]int64{
+		{0, 1, 2, 3, 4, 5},
+		{2, 5, 1, 4, 0, 3},
+		{5, 4, 3, 2, 1, 0},
+		{3, 0, 4, 1, 5, 2},
+	}
+
+	tf := transform.NewRandomRotate90(transform.WithP(1))
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		out := tf.Apply(s, rng)
+		assertJoint(t, out)
+		got := out.Mask.Int64Values()
+		k := -1
+		for j, r := range rotations {
+			if reflect.DeepEqual(r, got) {
+				k = j
+			}
+		}
+		if k < 0 {
+			t.Fatalf("Want one of rotations: %v\nGot: %v\n", rotations, got)
+		}
+		seen[k] = true
+
+		wantSize := []int64{3, 2}
+		if k%2 == 0 {
+			wantSize = []int64{2, 3}
+		}
+		if !reflect.DeepEqual(wantSize, out.Mask.MustSize()) {
+			t.Errorf("Want: %v\n", wantSize)
+			t.Errorf("Got: %v\n", out.Mask.MustSize())
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("Want all 4 rotations. Got: %v\n", seen)
+	}
+}
+
+func TestAffine(t *testing.T) {
+	s := newSample(3, 3, seq(9))
+	rng := rand.New(rand.NewSource(1))
+
+	// 90 degrees counterclockwise
+	tf := transform.NewAffine([2]float64{1, 1}, [2]float64{90, 90}, [2]float64{0, 0}, transform.WithP(1))
+	out := tf.Apply(s, rng)
+	assertMask(t, []int64{2, 5, 8, 1, 4, 7, 0, 3, 6}, out)
+	assertJoint(t, out)
+
+	// Zoom out: only center pixel stays inside.
+	tf = transform.NewAffine([2]float64{0.5, 0.5}, [2]float64{0, 0}, [2]float64{0, 0},
+		transform.WithP(1), transform.WithInterpolation(transform.Nearest), transform.WithFill(-1), transform.WithMaskFill(255))
+	out = tf.Apply(s, rng)
+	assertMask(t, []int64{255, 255, 255, 255, 4, 255, 255, 255, 255}, out)
+	wantImg := []int64{-1, -1, -1, -1, 4, -1, -1, -1, -1}
+	if got := imageInt64s(out); !reflect.DeepEqual(wantImg, got) {
+		t.Errorf("Want: %v\n", wantImg)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Random parameters
+	tf = transform.NewAffine([2]float64{0.8, 1.2}, [2]float64{-30, 30}, [2]float64{0.2, 0.2},
+		transform.WithP(1), transform.WithInterpolation(transform.Nearest))
+	s = newSample(5, 7, seq(35))
+	for i := 0; i < 5; i++ {
+		out = tf.Apply(s, rng)
+		assertJoint(t, out)
+	}
+}
+
+func TestRandomResizedCrop(t *testing.T) {
+	s := newSample(4, 6, seq(24))
+	rng := rand.New(rand.NewSource(1))
+
+	tf := transform.NewRandomResizedCrop(3, 5, transform.WithInterpolation(transform.Nearest))
+	for i := 0; i < 10; i++ {
+		out := tf.Apply(s, rng)
+		assertJoint(t, out)
+		if got := out.Image.MustSize(); !reflect.DeepEqual([]int64{1, 3, 5}, got) {
+			t.Errorf("Want: %v\n", []int64{1, 3, 5})
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+
+	// Not cropped: the whole image is resized.
+	tf = transform.NewRandomResizedCrop(4, 6, transform.WithP(0))
+	out := tf.Apply(s, rng)
+	assertMask(t, seq(24), out)
+	assertJoint(t, out)
+}
+
+func TestPadIfNeeded(t *testing.T) {
+	s := newSample(2, 2, seq(4))
+	rng := rand.New(rand.NewSource(1))
+
+	tf := transform.NewPadIfNeeded(3, 4, transform.WithFill(-1), transform.WithMaskFill(255))
+	out := tf.Apply(s, rng)
+	assertMask(t, []int64{
+		255, 0, 1, 255,
+		255, 2, 3, 255,
+		255, 255, 255, 255,
+	}, out)
+	wantImg := []int64{
+		-1, 0, 1, -1,
+		-1, 2, 3, -1,
+		-1, -1, -1, -1,
+	}
+	if got := imageInt64s(out); !reflect.DeepEqual(wantImg, got) {
+		t.Errorf("Want: %v\n", wantImg)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Large enough
+	out = transform.NewPadIfNeeded(2, 1).Apply(s, rng)
+	assertMask(t, seq(4), out)
+}
@@ -0,0 +1,590 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// NOTE. Photometric transforms change image only and never masks. They expect
+// images of shape [C, H, W] with values in [0, 1] (see dutil.ImageToTensor),
+// are computed on CPU and clamp results to [0, 1].
+
+// imagePixels returns values of image tensor of shape [C, H, W].
+func imagePixels(x *ts.Tensor) (vals []float64, c, h, w int) {
+	size := x.MustSize()
+	if len(size) != 3 {
+		log.Fatalf("Expected image of shape [C, H, W]. Got: %v\n", size)
+	}
+
+	cpu := x.MustTo(gotch.CPU, false).MustTotype(gotch.Double, true)
+	vals = cpu.Float64Values()
+	cpu.MustDrop()
+
+	return vals, int(size[0]), int(size[1]), int(size[2])
+}
+
+// pixelsTensor creates an image tensor of shape [C, H, W] from values with
+// dtype and device of like.
+func pixelsTensor(vals []float64, c, h, w int, like *ts.Tensor) *ts.Tensor {
+	x := ts.MustOfSlice(vals).MustView([]int64{int64(c), int64(h), int64(w)}, true)
+	return x.MustTotype(like.DType(), true).MustTo(like.MustDevice(), true)
+}
+
+// mapImage transforms a copy of image values in place with fn. Masks are kept.
+func mapImage(s *dutil.SegmentationSample, fn func(vals []float64, c, h, w int)) *dutil.SegmentationSample {
+	return mapSample(s, func(x *ts.Tensor, isMask bool) *ts.Tensor {
+		if isMask {
+			return x.MustShallowClone()
+		}
+		vals, c, h, w := imagePixels(x)
+		fn(vals, c, h, w)
+		return pixelsTensor(vals, c, h, w, x)
+	})
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// BrightnessContrast randomly changes image brightness and contrast:
+// x' = x * (1 + contrast) + brightness with contrast and brightness sampled
+// from [-Contrast, Contrast] and [-Brightness, Brightness] respectively.
+type BrightnessContrast struct {
+	Brightness float64
+	Contrast   float64
+	Options
+}
+
+// NewBrightnessContrast creates a new BrightnessContrast, e.g. NewBrightnessContrast(0.2, 0.2).
+func NewBrightnessContrast(brightness, contrast float64, options ...Option) *BrightnessContrast {
+	return &BrightnessContrast{
+		Brightness: brightness,
+		Contrast:   contrast,
+		Options:    NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *BrightnessContrast) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	alpha := 1 + uniform([2]float64{-t.Contrast, t.Contrast}, rng)
+	beta := uniform([2]float64{-t.Brightness, t.Brightness}, rng)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		for i, v := range vals {
+			vals[i] = clamp01(v*alpha + beta)
+		}
+	})
+}
+
+// Gamma applies random gamma correction x' = x^gamma.
+type Gamma struct {
+	Gamma [2]float64 // range of gamma
+	Options
+}
+
+// NewGamma creates a new Gamma, e.g. NewGamma([2]float64{0.8, 1.2}).
+func NewGamma(gamma [2]float64, options ...Option) *Gamma {
+	return &Gamma{
+		Gamma:   gamma,
+		Options: NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *Gamma) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	gamma := uniform(t.Gamma, rng)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		for i, v := range vals {
+			vals[i] = math.Pow(clamp01(v), gamma)
+		}
+	})
+}
+
+// rgbToHSV converts RGB in [0, 1] to hue in degrees [0, 360), saturation
+// and value in [0, 1].
+func rgbToHSV(r, g, b float64) (hue, sat, val float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	d := max - min
+	val = max
+	if max > 0 {
+		sat = d / max
+	}
+	if d == 0 {
+		return 0, sat, val
+	}
+
+	switch max {
+	case r:
+		hue = 60 * math.Mod((g-b)/d, 6)
+	case g:
+		hue = 60 * ((b-r)/d + 2)
+	default:
+		hue = 60 * ((r-g)/d + 4)
+	}
+	if hue < 0 {
+		hue += 360
+	}
+
+	return hue, sat, val
+}
+
+// hsvToRGB converts hue in degrees, saturation and value in [0, 1] to RGB.
+func hsvToRGB(hue, sat, val float64) (r, g, b float64) {
+	hue = math.Mod(hue, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	c := val * sat
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := val - c
+
+	switch int(hue / 60) {
+	case 0:
+		r, g, b = c, x, 0
+	case 1:
+		r, g, b = x, c, 0
+	case 2:
+		r, g, b = 0, c, x
+	case 3:
+		r, g, b = 0, x, c
+	case 4:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return r + m, g + m, b + m
+}
+
+// HueSaturation randomly shifts hue (in degrees) and saturation and value
+// (in [0, 1]) of RGB images in HSV color space. Images with other than 3
+// channels are returned unchanged.
+type HueSaturation struct {
+	Hue        float64 // maximum absolute hue shift in degrees
+	Saturation float64 // maximum absolute saturation shift
+	Value      float64 // maximum absolute value shift
+	Options
+}
+
+// NewHueSaturation creates a new HueSaturation, e.g. NewHueSaturation(20, 0.3, 0.2).
+func NewHueSaturation(hue, saturation, value float64, options ...Option) *HueSaturation {
+	return &HueSaturation{
+		Hue:        hue,
+		Saturation: saturation,
+		Value:      value,
+		Options:    NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *HueSaturation) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) || s.Image.MustSize()[0] != 3 {
+		return cloneSample(s)
+	}
+
+	dh := uniform([2]float64{-t.Hue, t.Hue}, rng)
+	ds := uniform([2]float64{-t.Saturation, t.Saturation}, rng)
+	dv := uniform([2]float64{-t.Value, t.Value}, rng)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		n := h * w
+		for i := 0; i < n; i++ {
+			hue, sat, val := rgbToHSV(clamp01(vals[i]), clamp01(vals[n+i]), clamp01(vals[2*n+i]))
+			r, g, b := hsvToRGB(hue+dh, clamp01(sat+ds), clamp01(val+dv))
+			vals[i], vals[n+i], vals[2*n+i] = r, g, b
+		}
+	})
+}
+
+// GaussianNoise adds zero-mean Gaussian noise of random standard deviation.
+type GaussianNoise struct {
+	Std [2]float64 // range of noise standard deviation
+	Options
+}
+
+// NewGaussianNoise creates a new GaussianNoise, e.g. NewGaussianNoise([2]float64{0.01, 0.05}).
+func NewGaussianNoise(std [2]float64, options ...Option) *GaussianNoise {
+	return &GaussianNoise{
+		Std:     std,
+		Options: NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *GaussianNoise) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	std := uniform(t.Std, rng)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		for i, v := range vals {
+			vals[i] = clamp01(v + rng.NormFloat64()*std)
+		}
+	})
+}
+
+// gaussianKernel returns normalized 1D Gaussian kernel of radius ceil(3*sigma).
+func gaussianKernel(sigma float64) []float64 {
+	radius := int(math.Ceil(3 * sigma))
+	if radius < 1 {
+		radius = 1
+	}
+	kernel := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// convolvePlane convolves a h x w plane with a separable kernel. Borders are
+// extended with edge values.
+func convolvePlane(plane []float64, h, w int, kernel []float64) {
+	radius := len(kernel) / 2
+	clampIdx := func(i, n int) int {
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+
+	tmp := make([]float64, len(plane))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var v float64
+			for k, kv := range kernel {
+				v += kv * plane[y*w+clampIdx(x+k-radius, w)]
+			}
+			tmp[y*w+x] = v
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var v float64
+			for k, kv := range kernel {
+				v += kv * tmp[clampIdx(y+k-radius, h)*w+x]
+			}
+			plane[y*w+x] = v
+		}
+	}
+}
+
+// GaussianBlur blurs image with Gaussian kernel of random standard deviation.
+type GaussianBlur struct {
+	Sigma [2]float64 // range of kernel standard deviation in pixels
+	Options
+}
+
+// NewGaussianBlur creates a new GaussianBlur, e.g. NewGaussianBlur([2]float64{0.5, 1.5}).
+func NewGaussianBlur(sigma [2]float64, options ...Option) *GaussianBlur {
+	return &GaussianBlur{
+		Sigma:   sigma,
+		Options: NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *GaussianBlur) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	sigma := uniform(t.Sigma, rng)
+	if sigma <= 0 {
+		return cloneSample(s)
+	}
+	kernel := gaussianKernel(sigma)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		for ch := 0; ch < c; ch++ {
+			convolvePlane(vals[ch*h*w:(ch+1)*h*w], h, w, kernel)
+		}
+	})
+}
+
+// claheBins is number of histogram bins of CLAHE.
+const claheBins = 256
+
+// clahePlane equalizes a h x w plane of values in [0, 1] in place with
+// contrast limited adaptive histogram equalization.
+func clahePlane(plane []float64, h, w int, clipLimit float64, tilesY, tilesX int) {
+	if tilesY > h {
+		tilesY = h
+	}
+	if tilesX > w {
+		tilesX = w
+	}
+	tileH := (h + tilesY - 1) / tilesY
+	tileW := (w + tilesX - 1) / tilesX
+	// Recompute number of tiles so that no tile is empty.
+	tilesY = (h + tileH - 1) / tileH
+	tilesX = (w + tileW - 1) / tileW
+
+	bin := func(v float64) int {
+		return int(math.Round(clamp01(v) * (claheBins - 1)))
+	}
+
+	// Mapping of each tile from bin to equalized value.
+	luts := make([][]float64, tilesY*tilesX)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			hist := make([]float64, claheBins)
+			var area float64
+			for y := ty * tileH; y < (ty+1)*tileH && y < h; y++ {
+				for x := tx * tileW; x < (tx+1)*tileW && x < w; x++ {
+					hist[bin(plane[y*w+x])]++
+					area++
+				}
+			}
+
+			// Clip histogram and redistribute excess uniformly.
+			limit := math.Max(1, clipLimit*area/claheBins)
+			var excess float64
+			for b, n := range hist {
+				if n > limit {
+					excess += n - limit
+					hist[b] = limit
+				}
+			}
+			lut := make([]float64, claheBins)
+			var cdf float64
+			for b, n := range hist {
+				cdf += n + excess/claheBins
+				lut[b] = cdf / area
+			}
+			luts[ty*tilesX+tx] = lut
+		}
+	}
+
+	// Interpolate mappings of the 4 nearest tile centers.
+	tileCoord := func(p float64, size, n int) (i0, i1 int, f float64) {
+		t := (p+0.5)/float64(size) - 0.5
+		i0 = int(math.Floor(t))
+		f = t - float64(i0)
+		switch {
+		case i0 < 0:
+			return 0, 0, 0
+		case i0 >= n-1:
+			return n - 1, n - 1, 0
+		}
+		return i0, i0 + 1, f
+	}
+	for y := 0; y < h; y++ {
+		y0, y1, fy := tileCoord(float64(y), tileH, tilesY)
+		for x := 0; x < w; x++ {
+			x0, x1, fx := tileCoord(float64(x), tileW, tilesX)
+			b := bin(plane[y*w+x])
+			top := luts[y0*tilesX+x0][b]*(1-fx) + luts[y0*tilesX+x1][b]*fx
+			bottom := luts[y1*tilesX+x0][b]*(1-fx) + luts[y1*tilesX+x1][b]*fx
+			plane[y*w+x] = top*(1-fy) + bottom*fy
+		}
+	}
+}
+
+// CLAHE applies contrast limited adaptive histogram equalization to luma of
+// RGB images (chroma is kept) or to each channel of other images.
+// Ref. https://en.wikipedia.org/wiki/Adaptive_histogram_equalization#Contrast_Limited_AHE
+type CLAHE struct {
+	ClipLimit float64 // histogram clip limit relative to uniform histogram
+	TileGrid  [2]int  // number of tiles in rows and columns
+	Options
+}
+
+// NewCLAHE creates a new CLAHE with clip limit 4 and 8x8 tiles by default.
+func NewCLAHE(options ...Option) *CLAHE {
+	return &CLAHE{
+		ClipLimit: 4,
+		TileGrid:  [2]int{8, 8},
+		Options:   NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *CLAHE) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) {
+		return cloneSample(s)
+	}
+
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		n := h * w
+		if c != 3 {
+			for ch := 0; ch < c; ch++ {
+				clahePlane(vals[ch*n:(ch+1)*n], h, w, t.ClipLimit, t.TileGrid[0], t.TileGrid[1])
+			}
+			return
+		}
+
+		// ITU-R BT.601 luma
+		luma := make([]float64, n)
+		for i := range luma {
+			luma[i] = 0.299*vals[i] + 0.587*vals[n+i] + 0.114*vals[2*n+i]
+		}
+		equalized := append([]float64(nil), luma...)
+		clahePlane(equalized, h, w, t.ClipLimit, t.TileGrid[0], t.TileGrid[1])
+		for i := range luma {
+			d := equalized[i] - luma[i]
+			for ch := 0; ch < 3; ch++ {
+				vals[ch*n+i] = clamp01(vals[ch*n+i] + d)
+			}
+		}
+	})
+}
+
+// JPEGCompression degrades image with JPEG compression artifacts of random
+// quality. Images with other than 1 or 3 channels are returned unchanged.
+type JPEGCompression struct {
+	Quality [2]int // range of JPEG quality in [1, 100]
+	Options
+}
+
+// NewJPEGCompression creates a new JPEGCompression, e.g. NewJPEGCompression([2]int{50, 95}).
+// Quality values are clamped to [1, 100] and ordered.
+func NewJPEGCompression(quality [2]int, options ...Option) *JPEGCompression {
+	for i, q := range quality {
+		switch {
+		case q < 1:
+			quality[i] = 1
+		case q > 100:
+			quality[i] = 100
+		}
+	}
+	if quality[0] > quality[1] {
+		quality[0], quality[1] = quality[1], quality[0]
+	}
+
+	return &JPEGCompression{
+		Quality: quality,
+		Options: NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *JPEGCompression) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if c := s.Image.MustSize()[0]; skip(t.P, rng) || (c != 1 && c != 3) {
+		return cloneSample(s)
+	}
+
+	quality := t.Quality[0] + rng.Intn(t.Quality[1]-t.Quality[0]+1)
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		n := h * w
+		toUint8 := func(v float64) uint8 {
+			return uint8(math.Round(clamp01(v) * 255))
+		}
+
+		var img image.Image
+		if c == 1 {
+			gray := image.NewGray(image.Rect(0, 0, w, h))
+			for i := range gray.Pix {
+				gray.Pix[i] = toUint8(vals[i])
+			}
+			img = gray
+		} else {
+			rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+			for i := 0; i < n; i++ {
+				rgba.Pix[4*i] = toUint8(vals[i])
+				rgba.Pix[4*i+1] = toUint8(vals[n+i])
+				rgba.Pix[4*i+2] = toUint8(vals[2*n+i])
+				rgba.Pix[4*i+3] = 255
+			}
+			img = rgba
+		}
+
+		// Image is left unchanged if it can't be encoded (e.g. larger than
+		// JPEG maximum size).
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return
+		}
+		decoded, err := jpeg.Decode(&buf)
+		if err != nil {
+			return
+		}
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				if c == 1 {
+					vals[i] = float64(color.GrayModel.Convert(decoded.At(x, y)).(color.Gray).Y) / 255
+					continue
+				}
+				r, g, b, _ := decoded.At(x, y).RGBA()
+				vals[i], vals[n+i], vals[2*n+i] = float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff
+			}
+		}
+	})
+}
+
+// CoarseDropout fills random rectangular holes of image with Fill value
+// (cutout). Number of holes and hole sizes in pixels are sampled from
+// [1, MaxHoles], [1, MaxHeight] and [1, MaxWidth]. It is a no-op if any of
+// them is less than 1 or image is empty.
+// Ref. https://arxiv.org/abs/1708.04552
+type CoarseDropout struct {
+	MaxHoles  int
+	MaxHeight int
+	MaxWidth  int
+	Options
+}
+
+// NewCoarseDropout creates a new CoarseDropout, e.g. NewCoarseDropout(8, 16, 16).
+func NewCoarseDropout(maxHoles, maxHeight, maxWidth int, options ...Option) *CoarseDropout {
+	return &CoarseDropout{
+		MaxHoles:  maxHoles,
+		MaxHeight: maxHeight,
+		MaxWidth:  maxWidth,
+		Options:   NewOptions(options...),
+	}
+}
+
+// Apply implements Transform interface.
+func (t *CoarseDropout) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	if skip(t.P, rng) || t.MaxHoles < 1 || t.MaxHeight < 1 || t.MaxWidth < 1 {
+		return cloneSample(s)
+	}
+	if size := s.Image.MustSize(); size[1] == 0 || size[2] == 0 {
+		return cloneSample(s)
+	}
+
+	return mapImage(s, func(vals []float64, c, h, w int) {
+		holes := 1 + rng.Intn(t.MaxHoles)
+		for i := 0; i < holes; i++ {
+			hh := 1 + rng.Intn(t.MaxHeight)
+			hw := 1 + rng.Intn(t.MaxWidth)
+			top := rng.Intn(h)
+			left := rng.Intn(w)
+			for ch := 0; ch < c; ch++ {
+				for y := top; y < top+hh && y < h; y++ {
+					for x := left; x < left+hw && x < w; x++ {
+						vals[(ch*h+y)*w+x] = t.Fill
+					}
+				}
+			}
+		}
+	})
+}
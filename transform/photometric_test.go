@@ -0,0 +1,283 @@
+package transform_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+	"github.com/sugarme/iseg/transform"
+)
+
+// newImageSample creates a sample of image of shape [C, H, W] with values
+// from fn and mask 0, 1, 2, ...
+func newImageSample(c, h, w int64, fn func(ch, y, x int64) float64) *dutil.SegmentationSample {
+	vals := make([]float32, c*h*w)
+	for ch := int64(0); ch < c; ch++ {
+		for y := int64(0); y < h; y++ {
+			for x := int64(0); x < w; x++ {
+				vals[(ch*h+y)*w+x] = float32(fn(ch, y, x))
+			}
+		}
+	}
+
+	return &dutil.SegmentationSample{
+		Name:  "sample",
+		Image: ts.MustOfSlice(vals).MustView([]int64{c, h, w}, true),
+		Mask:  ts.MustOfSlice(seq(h*w)).MustView([]int64{h, w}, true),
+	}
+}
+
+func randomImageSample(c, h, w int64) *dutil.SegmentationSample {
+	rng := rand.New(rand.NewSource(1))
+	return newImageSample(c, h, w, func(_, _, _ int64) float64 {
+		return rng.Float64()
+	})
+}
+
+func assertClose(t *testing.T, want, got []float64, tol float64) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("Want %v values. Got: %v\n", len(want), len(got))
+	}
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > tol {
+			t.Errorf("Want: %v\n", want)
+			t.Errorf("Got: %v\n", got)
+			return
+		}
+	}
+}
+
+func minMax(vals []float64) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+
+	return min, max
+}
+
+func TestPhotometric_MaskUnchanged(t *testing.T) {
+	tests := []struct {
+		name string
+		tf   transform.Transform
+	}{
+		{"BrightnessContrast", transform.NewBrightnessContrast(0.3, 0.3, transform.WithP(1))},
+		{"Gamma", transform.NewGamma([2]float64{0.5, 2}, transform.WithP(1))},
+		{"HueSaturation", transform.NewHueSaturation(30, 0.3, 0.3, transform.WithP(1))},
+		{"GaussianNoise", transform.NewGaussianNoise([2]float64{0.05, 0.1}, transform.WithP(1))},
+		{"GaussianBlur", transform.NewGaussianBlur([2]float64{0.5, 1.5}, transform.WithP(1))},
+		{"CLAHE", transform.NewCLAHE(transform.WithP(1))},
+		{"JPEGCompression", transform.NewJPEGCompression([2]int{10, 50}, transform.WithP(1))},
+		{"CoarseDropout", transform.NewCoarseDropout(3, 4, 4, transform.WithP(1))},
+	}
+
+	s := randomImageSample(3, 8, 10)
+	input := s.Image.Float64Values()
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		out := tt.tf.Apply(s, rng)
+		assertMask(t, seq(80), out)
+
+		vals := out.Image.Float64Values()
+		if !reflect.DeepEqual(s.Image.MustSize(), out.Image.MustSize()) {
+			t.Errorf("%v - Want: %v\n", tt.name, s.Image.MustSize())
+			t.Errorf("%v - Got: %v\n", tt.name, out.Image.MustSize())
+		}
+		if min, max := minMax(vals); min < 0 || max > 1 {
+			t.Errorf("%v - Want values in [0, 1]. Got: [%v, %v]\n", tt.name, min, max)
+		}
+		if reflect.DeepEqual(input, vals) {
+			t.Errorf("%v - Expected changed image.", tt.name)
+		}
+	}
+
+	// Input is unchanged.
+	if !reflect.DeepEqual(input, s.Image.Float64Values()) {
+		t.Errorf("Expected unchanged input image.")
+	}
+}
+
+func TestBrightnessContrast(t *testing.T) {
+	s := randomImageSample(3, 4, 4)
+	rng := rand.New(rand.NewSource(1))
+
+	out := transform.NewBrightnessContrast(0, 0, transform.WithP(1)).Apply(s, rng)
+	assertClose(t, s.Image.Float64Values(), out.Image.Float64Values(), 1e-6)
+
+	// Brightness only shifts all values by the same amount.
+	s = newImageSample(1, 2, 2, func(_, y, x int64) float64 { return 0.4 + 0.1*float64(y*2+x) })
+	out = transform.NewBrightnessContrast(0.2, 0, transform.WithP(1)).Apply(s, rng)
+	in, got := s.Image.Float64Values(), out.Image.Float64Values()
+	for i := range got {
+		if d := got[i] - in[i] - (got[0] - in[0]); math.Abs(d) > 1e-6 {
+			t.Errorf("Want constant shift. Got: %v -> %v\n", in, got)
+			break
+		}
+	}
+}
+
+func TestGamma(t *testing.T) {
+	s := newImageSample(1, 1, 3, func(_, _, x int64) float64 { return float64(x) / 2 })
+	out := transform.NewGamma([2]float64{2, 2}, transform.WithP(1)).Apply(s, rand.New(rand.NewSource(1)))
+	assertClose(t, []float64{0, 0.25, 1}, out.Image.Float64Values(), 1e-6)
+}
+
+func TestHueSaturation(t *testing.T) {
+	s := randomImageSample(3, 4, 4)
+	rng := rand.New(rand.NewSource(1))
+
+	// RGB -> HSV -> RGB round trip
+	out := transform.NewHueSaturation(0, 0, 0, transform.WithP(1)).Apply(s, rng)
+	assertClose(t, s.Image.Float64Values(), out.Image.Float64Values(), 1e-6)
+
+	// Hue shift keeps gray pixels.
+	gray := newImageSample(3, 2, 2, func(_, y, x int64) float64 { return 0.25 * float64(y*2+x) })
+	out = transform.NewHueSaturation(180, 0, 0, transform.WithP(1)).Apply(gray, rng)
+	assertClose(t, gray.Image.Float64Values(), out.Image.Float64Values(), 1e-6)
+
+	// Single channel image is unchanged.
+	single := randomImageSample(1, 4, 4)
+	out = transform.NewHueSaturation(30, 0.3, 0.3, transform.WithP(1)).Apply(single, rng)
+	assertClose(t, single.Image.Float64Values(), out.Image.Float64Values(), 0)
+}
+
+func TestGaussianNoise(t *testing.T) {
+	s := newImageSample(1, 8, 8, func(_, _, _ int64) float64 { return 0.5 })
+	tf := transform.NewGaussianNoise([2]float64{0.1, 0.1}, transform.WithP(1))
+
+	want := tf.Apply(s, rand.New(rand.NewSource(3))).Image.Float64Values()
+	got := tf.Apply(s, rand.New(rand.NewSource(3))).Image.Float64Values()
+	assertClose(t, want, got, 0)
+
+	var sum, sq float64
+	for _, v := range got {
+		sum += v - 0.5
+		sq += (v - 0.5) * (v - 0.5)
+	}
+	n := float64(len(got))
+	if mean, std := sum/n, math.Sqrt(sq/n); math.Abs(mean) > 0.05 || std < 0.05 || std > 0.15 {
+		t.Errorf("Want noise of mean 0 and std 0.1. Got: %v and %v\n", mean, std)
+	}
+}
+
+func TestGaussianBlur(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tf := transform.NewGaussianBlur([2]float64{1, 1}, transform.WithP(1))
+
+	// Constant image stays constant.
+	s := newImageSample(1, 5, 5, func(_, _, _ int64) float64 { return 0.3 })
+	out := tf.Apply(s, rng)
+	assertClose(t, s.Image.Float64Values(), out.Image.Float64Values(), 1e-6)
+
+	// Impulse is spread symmetrically with preserved sum.
+	s = newImageSample(1, 9, 9, func(_, y, x int64) float64 {
+		if y == 4 && x == 4 {
+			return 1
+		}
+		return 0
+	})
+	vals := tf.Apply(s, rng).Image.Float64Values()
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+	if math.Abs(sum-1) > 1e-5 {
+		t.Errorf("Want: %v\n", 1)
+		t.Errorf("Got: %v\n", sum)
+	}
+	if center := vals[40]; center >= 1 || center <= vals[39] || vals[39] != vals[41] || vals[31] != vals[49] {
+		t.Errorf("Expected symmetric blur of impulse. Got: %v\n", vals)
+	}
+}
+
+func TestCLAHE(t *testing.T) {
+	// Low contrast gradient
+	s := newImageSample(1, 16, 16, func(_, y, x int64) float64 { return 0.4 + 0.2*float64(y*16+x)/255 })
+	out := transform.NewCLAHE(transform.WithP(1)).Apply(s, rand.New(rand.NewSource(1)))
+
+	inMin, inMax := minMax(s.Image.Float64Values())
+	outMin, outMax := minMax(out.Image.Float64Values())
+	if outMax-outMin <= inMax-inMin {
+		t.Errorf("Want contrast larger than: %v\n", inMax-inMin)
+		t.Errorf("Got: %v\n", outMax-outMin)
+	}
+
+	// Order of pixels is preserved within a tile.
+	tf := transform.NewCLAHE(transform.WithP(1))
+	tf.TileGrid = [2]int{1, 1}
+	vals := tf.Apply(s, rand.New(rand.NewSource(1))).Image.Float64Values()
+	for i := 1; i < len(vals); i++ {
+		if vals[i] < vals[i-1] {
+			t.Errorf("Expected monotonic mapping. Got: %v\n", vals)
+			break
+		}
+	}
+}
+
+func TestJPEGCompression(t *testing.T) {
+	s := newImageSample(3, 16, 16, func(ch, y, x int64) float64 { return float64(ch+1) * float64(x+y) / 100 })
+	rng := rand.New(rand.NewSource(1))
+
+	out := transform.NewJPEGCompression([2]int{95, 95}, transform.WithP(1)).Apply(s, rng)
+	assertClose(t, s.Image.Float64Values(), out.Image.Float64Values(), 0.05)
+
+	gray := newImageSample(1, 16, 16, func(_, y, x int64) float64 { return float64(x+y) / 30 })
+	out = transform.NewJPEGCompression([2]int{95, 95}, transform.WithP(1)).Apply(gray, rng)
+	assertClose(t, gray.Image.Float64Values(), out.Image.Float64Values(), 0.05)
+
+	// Out of range and reversed qualities are clamped and ordered.
+	tf := transform.NewJPEGCompression([2]int{101, 0}, transform.WithP(1))
+	if tf.Quality != [2]int{1, 100} {
+		t.Errorf("Want: %v\n", [2]int{1, 100})
+		t.Errorf("Got: %v\n", tf.Quality)
+	}
+	tf.Apply(s, rng)
+}
+
+func TestCoarseDropout(t *testing.T) {
+	s := newImageSample(2, 6, 6, func(_, _, _ int64) float64 { return 1 })
+	out := transform.NewCoarseDropout(1, 2, 2, transform.WithP(1), transform.WithFill(0.5)).Apply(s, rand.New(rand.NewSource(1)))
+
+	vals := out.Image.Float64Values()
+	var filled int
+	for i, v := range vals[:36] {
+		if v != vals[36+i] {
+			t.Fatalf("Expected same hole in all channels. Got: %v\n", vals)
+		}
+		switch v {
+		case 0.5:
+			filled++
+		case 1:
+		default:
+			t.Fatalf("Want values 0.5 or 1. Got: %v\n", v)
+		}
+	}
+	if filled < 1 || filled > 4 {
+		t.Errorf("Want 1 to 4 filled pixels. Got: %v\n", filled)
+	}
+}
+
+func TestCoarseDropout_Zero(t *testing.T) {
+	s := newImageSample(1, 6, 6, func(_, _, _ int64) float64 { return 1 })
+	for _, tf := range []*transform.CoarseDropout{
+		transform.NewCoarseDropout(0, 2, 2, transform.WithP(1)),
+		transform.NewCoarseDropout(1, 0, 2, transform.WithP(1)),
+		transform.NewCoarseDropout(1, 2, 0, transform.WithP(1)),
+	} {
+		out := tf.Apply(s, rand.New(rand.NewSource(1)))
+		assertClose(t, s.Image.Float64Values(), out.Image.Float64Values(), 0)
+	}
+
+	empty := &dutil.SegmentationSample{Image: ts.MustZeros([]int64{1, 0, 4}, gotch.Float, gotch.CPU)}
+	out := transform.NewCoarseDropout(1, 2, 2, transform.WithP(1)).Apply(empty, rand.New(rand.NewSource(1)))
+	if got := out.Image.MustSize(); got[1] != 0 || got[2] != 4 {
+		t.Errorf("Want size: %v\n", []int64{1, 0, 4})
+		t.Errorf("Got size: %v\n", got)
+	}
+}
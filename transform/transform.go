@@ -0,0 +1,274 @@
+package transform
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sugarme/gotch/ts"
+
+	"github.com/sugarme/iseg/dutil"
+)
+
+// Interpolation is a resampling method of images. Values match libtorch
+// grid sampler modes.
+type Interpolation int64
+
+const (
+	Bilinear Interpolation = iota
+	Nearest
+	Bicubic
+)
+
+// Transform transforms an image and its masks jointly.
+//
+// Apply returns a new sample and must not modify or drop tensors of input
+// sample. Masks are always resampled with nearest neighbour interpolation so
+// that class indices are preserved.
+type Transform interface {
+	Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample
+}
+
+// Options holds optional settings shared by transforms.
+type Options struct {
+	P             float64       // probability of applying transform
+	Interpolation Interpolation // image interpolation. Masks always use nearest neighbour.
+	Fill          float64       // image value of pixels outside input image
+	MaskFill      int64         // mask value of pixels outside input mask, e.g. ignore index
+}
+
+type Option func(*Options)
+
+func NewOptions(options ...Option) Options {
+	opts := Options{
+		P:             0.5,
+		Interpolation: Bilinear,
+		Fill:          0,
+		MaskFill:      0,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithP(p float64) Option {
+	return func(o *Options) {
+		o.P = p
+	}
+}
+
+func WithInterpolation(interpolation Interpolation) Option {
+	return func(o *Options) {
+		o.Interpolation = interpolation
+	}
+}
+
+func WithFill(fill float64) Option {
+	return func(o *Options) {
+		o.Fill = fill
+	}
+}
+
+func WithMaskFill(maskFill int64) Option {
+	return func(o *Options) {
+		o.MaskFill = maskFill
+	}
+}
+
+// skip returns whether a transform with probability p should be skipped.
+func skip(p float64, rng *rand.Rand) bool {
+	return rng.Float64() >= p
+}
+
+// uniform returns a random number in [r[0], r[1]).
+func uniform(r [2]float64, rng *rand.Rand) float64 {
+	return r[0] + rng.Float64()*(r[1]-r[0])
+}
+
+// mapSample applies fn to image and masks of sample. Nil masks are kept nil.
+func mapSample(s *dutil.SegmentationSample, fn func(x *ts.Tensor, isMask bool) *ts.Tensor) *dutil.SegmentationSample {
+	out := &dutil.SegmentationSample{
+		Name:  s.Name,
+		Image: fn(s.Image, false),
+	}
+	if s.Mask != nil {
+		out.Mask = fn(s.Mask, true)
+	}
+	if s.Instance != nil {
+		out.Instance = fn(s.Instance, true)
+	}
+
+	return out
+}
+
+// cloneSample returns a sample of shallow clones of tensors of s.
+func cloneSample(s *dutil.SegmentationSample) *dutil.SegmentationSample {
+	return mapSample(s, func(x *ts.Tensor, _ bool) *ts.Tensor {
+		return x.MustShallowClone()
+	})
+}
+
+// dropSample drops tensors of sample.
+func dropSample(s *dutil.SegmentationSample) {
+	for _, x := range []*ts.Tensor{s.Image, s.Mask, s.Instance} {
+		if x != nil {
+			x.MustDrop()
+		}
+	}
+}
+
+// Compose applies transforms in order.
+type Compose struct {
+	Transforms []Transform
+}
+
+func NewCompose(transforms ...Transform) *Compose {
+	return &Compose{Transforms: transforms}
+}
+
+// Apply implements Transform interface.
+func (c *Compose) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	out := cloneSample(s)
+	for _, t := range c.Transforms {
+		next := t.Apply(out, rng)
+		dropSample(out)
+		out = next
+	}
+
+	return out
+}
+
+// Pipeline applies transforms with random generators derived from its seed
+// so that augmentation is reproducible. It is safe for concurrent use.
+type Pipeline struct {
+	transform Transform
+	mu        sync.Mutex
+	seed      int64
+	rng       *rand.Rand
+}
+
+// NewPipeline creates a new Pipeline of transforms applied in order.
+func NewPipeline(seed int64, transforms ...Transform) *Pipeline {
+	return &Pipeline{
+		transform: NewCompose(transforms...),
+		seed:      seed,
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Seed resets random generator of pipeline.
+func (p *Pipeline) Seed(seed int64) {
+	p.mu.Lock()
+	p.seed = seed
+	p.rng.Seed(seed)
+	p.mu.Unlock()
+}
+
+// Transform returns a transformed copy of sample. Random draws follow order
+// of calls, so use TransformItem where samples are transformed concurrently
+// or retried, e.g. by dutil.DataLoader workers.
+func (p *Pipeline) Transform(s *dutil.SegmentationSample) *dutil.SegmentationSample {
+	// Each call gets its own generator so that transforms run without lock.
+	p.mu.Lock()
+	rng := rand.New(rand.NewSource(p.rng.Int63()))
+	p.mu.Unlock()
+
+	return p.transform.Apply(s, rng)
+}
+
+// TransformItem returns a transformed copy of sample at index idx of a
+// dataset. Its random generator is seeded from pipeline seed, epoch and idx,
+// so that augmentation of a sample doesn't depend on order of calls.
+func (p *Pipeline) TransformItem(s *dutil.SegmentationSample, epoch, idx int) *dutil.SegmentationSample {
+	p.mu.Lock()
+	seed := dutil.EpochSeed(dutil.EpochSeed(p.seed, epoch), idx)
+	p.mu.Unlock()
+
+	return p.transform.Apply(s, rand.New(rand.NewSource(seed)))
+}
+
+// Apply implements Transform interface. Pipeline's own generator is not used.
+func (p *Pipeline) Apply(s *dutil.SegmentationSample, rng *rand.Rand) *dutil.SegmentationSample {
+	return p.transform.Apply(s, rng)
+}
+
+// Dataset wraps a dataset of *dutil.SegmentationSample items and transforms
+// every loaded item with a pipeline (see Pipeline.TransformItem). It
+// implements dutil.EpochDataset so that dutil.DataLoader augments each epoch
+// differently but reproducibly, regardless of number of workers.
+type Dataset struct {
+	dataset   dutil.Dataset
+	pipeline  *Pipeline
+	dropInput bool
+	epoch     int64 // accessed atomically
+}
+
+// NewDataset creates a new Dataset.
+//
+// dropInputOpt: Optional (default=false). Whether to drop tensors of items
+// loaded from ds after transforming. Set it for datasets creating new tensors
+// on every Item call such as dutil.SegmentationFolderDataset, but not for
+// datasets holding samples in memory such as dutil.SliceDataset.
+func NewDataset(ds dutil.Dataset, p *Pipeline, dropInputOpt ...bool) *Dataset {
+	dropInput := false
+	if len(dropInputOpt) > 0 {
+		dropInput = dropInputOpt[0]
+	}
+
+	return &Dataset{
+		dataset:   ds,
+		pipeline:  p,
+		dropInput: dropInput,
+	}
+}
+
+// Item implements dutil.Dataset interface.
+func (ds *Dataset) Item(idx int) (interface{}, error) {
+	item, err := ds.dataset.Item(idx)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := item.(*dutil.SegmentationSample)
+	if !ok {
+		err := fmt.Errorf("Expected item of type *dutil.SegmentationSample. Got: %T\n", item)
+		return nil, err
+	}
+
+	out := ds.pipeline.TransformItem(s, ds.Epoch(), idx)
+	if ds.dropInput {
+		dropSample(s)
+	}
+
+	return out, nil
+}
+
+// SetEpoch implements dutil.EpochDataset interface. Epoch is also passed to
+// wrapped dataset if it implements dutil.EpochDataset.
+func (ds *Dataset) SetEpoch(epoch int) {
+	atomic.StoreInt64(&ds.epoch, int64(epoch))
+	if d, ok := ds.dataset.(dutil.EpochDataset); ok {
+		d.SetEpoch(epoch)
+	}
+}
+
+// Epoch returns current epoch.
+func (ds *Dataset) Epoch() int {
+	return int(atomic.LoadInt64(&ds.epoch))
+}
+
+func (ds *Dataset) Len() int {
+	return ds.dataset.Len()
+}
+
+func (ds *Dataset) DType() reflect.Type {
+	return ds.dataset.DType()
+}
+
+func (ds *Dataset) ItemType() reflect.Type {
+	return ds.dataset.ItemType()
+}
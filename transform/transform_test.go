@@ -0,0 +1,136 @@
+package transform_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/iseg/dutil"
+	"github.com/sugarme/iseg/transform"
+)
+
+func newTestPipeline(seed int64) *transform.Pipeline {
+	return transform.NewPipeline(seed,
+		transform.NewHFlip(),
+		transform.NewVFlip(),
+		transform.NewRandomRotate90(),
+		transform.NewPadIfNeeded(4, 4, transform.WithMaskFill(255)),
+	)
+}
+
+func pipelineMasks(p *transform.Pipeline, s *dutil.SegmentationSample, n int) [][]int64 {
+	var masks [][]int64
+	for i := 0; i < n; i++ {
+		masks = append(masks, p.Transform(s).Mask.Int64Values())
+	}
+
+	return masks
+}
+
+func TestPipeline(t *testing.T) {
+	s := newSample(2, 3, seq(6))
+
+	want := pipelineMasks(newTestPipeline(42), s, 10)
+	got := pipelineMasks(newTestPipeline(42), s, 10)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	p := newTestPipeline(7)
+	other := pipelineMasks(p, s, 10)
+	if reflect.DeepEqual(want, other) {
+		t.Errorf("Expected different augmentation with different seed.")
+	}
+
+	p.Seed(42)
+	got = pipelineMasks(p, s, 10)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+
+	// Input is unchanged.
+	assertMask(t, seq(6), s)
+}
+
+func TestDataset(t *testing.T) {
+	samples := []*dutil.SegmentationSample{newSample(2, 3, seq(6))}
+	sds, err := dutil.NewSliceDataset(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := transform.NewDataset(sds, transform.NewPipeline(1, transform.NewHFlip(transform.WithP(1))))
+	if ds.Len() != 1 || ds.DType() != sds.DType() {
+		t.Errorf("Want: %v, %v\n", 1, sds.DType())
+		t.Errorf("Got: %v, %v\n", ds.Len(), ds.DType())
+	}
+
+	item, err := ds.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMask(t, []int64{2, 1, 0, 5, 4, 3}, item.(*dutil.SegmentationSample))
+
+	invalid, err := dutil.NewSliceDataset([]int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transform.NewDataset(invalid, transform.NewPipeline(1)).Item(0); err == nil {
+		t.Errorf("Expected invalid item type error.")
+	}
+}
+
+// loadMasks loads 2 epochs of 8 augmented samples with a seeded pipeline and
+// returns masks in loading order.
+func loadMasks(t *testing.T, workers int) [][]int64 {
+	samples := make([]*dutil.SegmentationSample, 8)
+	for i := range samples {
+		samples[i] = newSample(2, 3, seq(6))
+	}
+	sds, err := dutil.NewSliceDataset(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := dutil.NewBatchSampler(len(samples), 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewDataLoader(transform.NewDataset(sds, newTestPipeline(42)), s, dutil.WithWorkers(workers), dutil.WithPrefetch(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+
+	var masks [][]int64
+	for epoch := 0; epoch < 2; epoch++ {
+		dl.StartEpoch(epoch)
+		for dl.HasNext() {
+			batch, err := dl.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range batch.([]*dutil.SegmentationSample) {
+				masks = append(masks, item.Mask.Int64Values())
+			}
+		}
+	}
+
+	return masks
+}
+
+func TestDataset_Workers(t *testing.T) {
+	want := loadMasks(t, 0)
+	for _, workers := range []int{4, 4, 2} {
+		got := loadMasks(t, workers)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Want masks with %v workers: %v\n", workers, want)
+			t.Errorf("Got: %v\n", got)
+		}
+	}
+
+	// Epochs are augmented differently.
+	if reflect.DeepEqual(want[:8], want[8:]) {
+		t.Errorf("Expected different augmentation in different epochs.")
+	}
+}